@@ -0,0 +1,128 @@
+package common
+
+import (
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+var HttpRouter *httpRouter
+
+type httpRouter struct {
+	templates [][]string
+}
+
+func newHttpRouter(templates []string) *httpRouter {
+	r := &httpRouter{}
+	if len(templates) == 0 {
+		return r
+	}
+	klog.Infof("HTTP route templates: %v", templates)
+	for _, t := range templates {
+		r.templates = append(r.templates, splitHttpPath(t))
+	}
+	return r
+}
+
+// Route returns a low-cardinality route for the given path: either a matching user-defined template
+// or the path with numeric ids, UUIDs, and hashes replaced with placeholders.
+func (r *httpRouter) Route(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	path, _, _ = strings.Cut(path, "#")
+	path = strings.TrimSuffix(path, "...") // truncated by the parser
+	if path == "" || path == "*" {
+		return path
+	}
+	segments := splitHttpPath(path)
+	for _, t := range r.templates {
+		if matchHttpRoute(t, segments) {
+			return "/" + strings.Join(t, "/")
+		}
+	}
+	for i, s := range segments {
+		segments[i] = normalizeHttpPathSegment(s)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+func splitHttpPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func matchHttpRoute(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+	for i, t := range template {
+		if t == "*" || (strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}")) {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if t != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeHttpPathSegment(s string) string {
+	switch {
+	case s == "":
+		return s
+	case isDigits(s):
+		return "{id}"
+	case isUUID(s):
+		return "{uuid}"
+	case len(s) >= 16 && isHex(s) && hasDigits(s):
+		return "{hash}"
+	}
+	return s
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func hasDigits(s string) bool {
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			return true
+		}
+	}
+	return false
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpRouter(t *testing.T) {
+	r := newHttpRouter(nil)
+	assert.Equal(t, "/", r.Route("/"))
+	assert.Equal(t, "/api/v1/users/{id}", r.Route("/api/v1/users/12345?fields=name"))
+	assert.Equal(t, "/orders/{uuid}/items", r.Route("/orders/3f2b8c1e-4d5a-4b6c-9e7f-0a1b2c3d4e5f/items"))
+	assert.Equal(t, "/blobs/{hash}", r.Route("/blobs/9e107d9d372bb6826bd81d3542a419d6"))
+	assert.Equal(t, "/static/main.css", r.Route("/static/main.css"))
+	assert.Equal(t, "/api/v1/users/{id}/", r.Route("/api/v1/users/1/"))
+	assert.Equal(t, "/api/v1/aaaaaaaaaaaaaaaa", r.Route("/api/v1/aaaaaaaaaaaaaaaa"))
+	assert.Equal(t, "/api/v1/users", r.Route("/api/v1/users..."))
+
+	r = newHttpRouter([]string{"/api/v1/users/{login}", "/files/*/download"})
+	assert.Equal(t, "/api/v1/users/{login}", r.Route("/api/v1/users/john"))
+	assert.Equal(t, "/files/*/download", r.Route("/files/report.pdf/download"))
+	assert.Equal(t, "/api/v1/users/{id}/orders", r.Route("/api/v1/users/1/orders"))
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+)
+
+const (
+	kernelTimeCalibrationInterval = time.Minute
+	kernelTimeCalibrationAttempts = 5
+)
+
+var kernelTimeOffset struct {
+	lock       sync.Mutex
+	offset     int64 // wall time (ns since the epoch) minus CLOCK_MONOTONIC
+	calibrated time.Time
+}
+
+// KernelTimeToTime converts a CLOCK_MONOTONIC timestamp (e.g., obtained by bpf_ktime_get_ns) to wall time.
+// The offset between the clocks is recalibrated periodically to follow the adjustments of the system clock.
+func KernelTimeToTime(ns uint64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	kernelTimeOffset.lock.Lock()
+	if now := time.Now(); now.Sub(kernelTimeOffset.calibrated) > kernelTimeCalibrationInterval {
+		if offset, err := calibrateKernelTimeOffset(); err != nil {
+			klog.Warningln("failed to calibrate the kernel time offset:", err)
+		} else {
+			kernelTimeOffset.offset = offset
+		}
+		kernelTimeOffset.calibrated = now
+	}
+	offset := kernelTimeOffset.offset
+	kernelTimeOffset.lock.Unlock()
+	if offset == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ns)+offset)
+}
+
+// calibrateKernelTimeOffset reads the monotonic clock between two reads of the wall clock several times
+// and takes the attempt with the smallest gap (the least likely to be preempted)
+func calibrateKernelTimeOffset() (int64, error) {
+	var ts unix.Timespec
+	var offset int64
+	minGap := time.Duration(-1)
+	for i := 0; i < kernelTimeCalibrationAttempts; i++ {
+		before := time.Now()
+		if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+			return 0, err
+		}
+		after := time.Now()
+		if gap := after.Sub(before); minGap < 0 || gap < minGap {
+			minGap = gap
+			offset = before.Add(gap/2).UnixNano() - ts.Nano()
+		}
+	}
+	return offset, nil
+}
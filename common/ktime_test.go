@@ -0,0 +1,20 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestKernelTimeToTime(t *testing.T) {
+	assert.True(t, KernelTimeToTime(0).IsZero())
+
+	var ts unix.Timespec
+	require.NoError(t, unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts))
+	now := time.Now()
+	assert.WithinDuration(t, now, KernelTimeToTime(uint64(ts.Nano())), 10*time.Millisecond)
+	assert.WithinDuration(t, now.Add(-time.Second), KernelTimeToTime(uint64(ts.Nano()-int64(time.Second))), 10*time.Millisecond)
+}
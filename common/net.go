@@ -60,6 +60,7 @@ func init() {
 	if HttpFilter, err = newHttpFilter(*flags.ExcludeHTTPMetricsByPath); err != nil {
 		klog.Exitf("invalid HTTP filter: %s", err)
 	}
+	HttpRouter = newHttpRouter(*flags.HTTPRouteTemplates)
 }
 
 func IsIpPrivate(ip netaddr.IP) bool {
@@ -0,0 +1,255 @@
+package common
+
+import (
+	"regexp"
+	"strings"
+)
+
+var sqlPlaceholderList = regexp.MustCompile(`\(\s*\?(\s*,\s*\?)+\s*\)`)
+
+// ObfuscateSQL replaces string and numeric literals with `?` and collapses lists of placeholders, e.g.,
+// `SELECT * FROM users WHERE id IN (1, 2, 3) AND name = 'john'` -> `SELECT * FROM users WHERE id IN (?) AND name = ?`.
+// Double-quoted strings are treated as identifiers unless doubleQuotedStrings is set (MySQL).
+func ObfuscateSQL(query string, doubleQuotedStrings bool) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || (c == '"' && doubleQuotedStrings):
+			b.WriteByte('?')
+			i = skipSqlString(query, i+1, c)
+		case c == '"' || c == '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 2
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 4
+		case c == '$':
+			if tag, ok := sqlDollarQuoteTag(query[i:]); ok {
+				b.WriteByte('?')
+				end := strings.Index(query[i+len(tag):], tag)
+				if end < 0 {
+					return b.String()
+				}
+				i += 2*len(tag) + end
+				continue
+			}
+			b.WriteByte(c) // $1 placeholder
+			i++
+			for i < len(query) && isDigit(query[i]) {
+				b.WriteByte(query[i])
+				i++
+			}
+		case isDigit(c) || (c == '.' && i+1 < len(query) && isDigit(query[i+1])):
+			if i > 0 && isSqlIdentifierChar(query[i-1]) {
+				b.WriteByte(c)
+				i++
+				continue
+			}
+			b.WriteByte('?')
+			i = skipSqlNumber(query, i)
+		case isSqlIdentifierChar(c):
+			start := i
+			for i < len(query) && isSqlIdentifierChar(query[i]) {
+				i++
+			}
+			if i-start == 1 && i < len(query) && query[i] == '\'' && strings.IndexByte("EeNnXxBb", c) >= 0 {
+				continue // E'...', N'...', X'...', B'...' literals
+			}
+			b.WriteString(query[start:i])
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return sqlPlaceholderList.ReplaceAllString(b.String(), "(?)")
+}
+
+func skipSqlString(query string, i int, quote byte) int {
+	for i < len(query) {
+		switch query[i] {
+		case '\\':
+			i += 2
+			continue
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote { // escaped quote
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		i++
+	}
+	return len(query)
+}
+
+func skipSqlNumber(query string, i int) int {
+	if strings.HasPrefix(query[i:], "0x") || strings.HasPrefix(query[i:], "0X") {
+		i += 2
+		for i < len(query) && isHexDigit(query[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case isDigit(c) || c == '.':
+		case (c == 'e' || c == 'E') && i+1 < len(query):
+			if query[i+1] == '-' || query[i+1] == '+' {
+				i++
+			}
+		default:
+			return i
+		}
+		i++
+	}
+	return i
+}
+
+// sqlDollarQuoteTag returns the opening tag of a Postgres dollar-quoted string: $$ or $tag$
+func sqlDollarQuoteTag(s string) (string, bool) {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1], true
+		}
+		if !(c == '_' || isLetter(c) || (i > 1 && isDigit(c))) {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isSqlIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || isLetter(c) || isDigit(c) || c >= 0x80
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// commands whose first argument is not a key
+var redisNonKeyCommands = map[string]bool{
+	"AUTH": true, "HELLO": true, "ECHO": true, "EVAL": true, "EVAL_RO": true, "CONFIG": true, "CLIENT": true,
+	"PUBLISH": true, "SPUBLISH": true, "MIGRATE": true, "ACL": true,
+}
+
+// ObfuscateRedis strips the values of a Redis command keeping the key, e.g., `SET user:1 ...` -> `SET user:1 ?`.
+// The args are expected in the format returned by l7.ParseRedis: the first argument followed by ` ...`
+// if there are more.
+func ObfuscateRedis(cmd, args string) string {
+	if args == "" {
+		return cmd
+	}
+	key, more := strings.CutSuffix(args, "...")
+	key = strings.TrimSpace(key)
+	if key == "" || redisNonKeyCommands[strings.ToUpper(cmd)] {
+		key = "?"
+	}
+	res := cmd + " " + key
+	if more && key != "?" {
+		res += " ?"
+	}
+	return res
+}
+
+// ObfuscateMongo masks the values of nested documents in a Mongo command in the Extended JSON format keeping
+// the top-level scalars (the command, collection and database names, options), e.g.,
+// `{"find": "users","filter": {"email": "john@example.com"}}` -> `{"find": "users","filter": {"email": "?"}}`.
+func ObfuscateMongo(query string) string {
+	if !strings.HasPrefix(query, "{") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query))
+	var stack []byte // '{' or '['
+	expectKey := false
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '{' || c == '[':
+			stack = append(stack, c)
+			expectKey = c == '{'
+			b.WriteByte(c)
+			i++
+		case c == '}' || c == ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			b.WriteByte(c)
+			i++
+		case c == ',':
+			expectKey = len(stack) > 0 && stack[len(stack)-1] == '{'
+			b.WriteByte(c)
+			i++
+		case c == ':':
+			expectKey = false
+			b.WriteByte(c)
+			i++
+		case c == '"':
+			end := skipJsonString(query, i+1)
+			if expectKey || len(stack) <= 1 {
+				b.WriteString(query[i:end])
+			} else {
+				b.WriteString(`"?"`)
+			}
+			i = end
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			b.WriteByte(c)
+			i++
+		default: // numbers, true, false, null
+			start := i
+			for i < len(query) && strings.IndexByte(",:{}[]\" \t\n\r", query[i]) < 0 {
+				i++
+			}
+			if len(stack) <= 1 {
+				b.WriteString(query[start:i])
+			} else {
+				b.WriteString(`"?"`)
+			}
+		}
+	}
+	return b.String()
+}
+
+func skipJsonString(s string, i int) int {
+	for i < len(s) {
+		switch s[i] {
+		case '\\':
+			i += 2
+			continue
+		case '"':
+			return i + 1
+		}
+		i++
+	}
+	return len(s)
+}
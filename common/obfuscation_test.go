@@ -0,0 +1,40 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObfuscateSQL(t *testing.T) {
+	assert.Equal(t,
+		`SELECT * FROM users WHERE id IN (?) AND name = ? AND "t1"."col2" > -?`,
+		ObfuscateSQL(`SELECT * FROM users WHERE id IN (1, 2, 3) AND name = 'O''Brien' AND "t1"."col2" > -1.5e-3`, false))
+	assert.Equal(t,
+		`UPDATE t2 SET data = ?, raw = ?, hex = ? WHERE id = $1 /* 42 */`,
+		ObfuscateSQL(`UPDATE t2 SET data = E'a\'b', raw = $tag$ it's $$ $tag$, hex = 0xFF WHERE id = $1 /* 42 */`, false))
+	assert.Equal(t,
+		"INSERT INTO `users` (name, email) VALUES (?)",
+		ObfuscateSQL("INSERT INTO `users` (name, email) VALUES (\"john\", 'john@example.com')", true))
+	assert.Equal(t, `SELECT ? -- comment 1`, ObfuscateSQL(`SELECT 1 -- comment 1`, false))
+	assert.Equal(t, `SELECT ?`, ObfuscateSQL(`SELECT 'unterminated...`, false))
+	assert.Equal(t, `PREPARE s1 AS SELECT * FROM t WHERE a = $1`, ObfuscateSQL(`PREPARE s1 AS SELECT * FROM t WHERE a = $1`, false))
+}
+
+func TestObfuscateRedis(t *testing.T) {
+	assert.Equal(t, "PING", ObfuscateRedis("PING", ""))
+	assert.Equal(t, "GET user:1", ObfuscateRedis("GET", "user:1"))
+	assert.Equal(t, "SET user:1 ?", ObfuscateRedis("SET", "user:1 ..."))
+	assert.Equal(t, "AUTH ?", ObfuscateRedis("AUTH", "secret"))
+	assert.Equal(t, "auth ?", ObfuscateRedis("auth", "user ..."))
+}
+
+func TestObfuscateMongo(t *testing.T) {
+	assert.Equal(t,
+		`{"find": "users","filter": {"email": "?","age": {"$gt": "?"}},"limit": {"$numberInt":"?"},"$db": "shop"}`,
+		ObfuscateMongo(`{"find": "users","filter": {"email": "john@example.com","age": {"$gt": 18}},"limit": {"$numberInt":"1"},"$db": "shop"}`))
+	assert.Equal(t,
+		`{"insert": "users","ordered": true,"documents": [{"_id": {"$oid":"?"},"tags": ["?","?"]}]}`,
+		ObfuscateMongo(`{"insert": "users","ordered": true,"documents": [{"_id": {"$oid":"5f1d7f"},"tags": ["a","b"]}]}`))
+	assert.Equal(t, "<truncated>", ObfuscateMongo("<truncated>"))
+}
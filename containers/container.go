@@ -32,6 +32,8 @@ var (
 	gpuStatsWindow            = 15 * time.Second
 )
 
+const dnsMaxTrackedCnames = 1000
+
 type ContainerID string
 
 type ContainerNetwork struct {
@@ -83,9 +85,32 @@ type ActiveConnection struct {
 	BytesSent     uint64
 	BytesReceived uint64
 
-	http2Parser    *l7.Http2Parser
-	postgresParser *l7.PostgresParser
-	mysqlParser    *l7.MysqlParser
+	l7Parsers
+}
+
+type InboundConnection struct {
+	ListenAddr netaddr.IPPort
+	ClientAddr netaddr.IPPort
+	Pid        uint32
+	Fd         uint64
+	Timestamp  uint64
+	Closed     time.Time
+
+	l7Parsers
+}
+
+type l7Parsers struct {
+	http2Parser     *l7.Http2Parser
+	postgresParser  *l7.PostgresParser
+	mysqlParser     *l7.MysqlParser
+	cassandraParser *l7.CassandraParser
+	redisParser     *l7.RedisParser
+	memcachedParser *l7.MemcachedParser
+	mongoParser     *l7.MongoParser
+	mssqlParser     *l7.MssqlParser
+	mqttParser      *l7.MqttParser
+	pulsarParser    *l7.PulsarParser
+	fastcgiParser   *l7.FastcgiParser
 }
 
 type ListenDetails struct {
@@ -129,9 +154,13 @@ type Container struct {
 	lastConnectionAttempts   map[common.HostPort]time.Time
 	activeConnections        map[ConnectionKey]*ActiveConnection
 	connectionsByPidFd       map[PidFd]*ActiveConnection
+	inboundConnections       map[PidFd]*InboundConnection
 
-	l7Stats  L7Stats
-	dnsStats *L7Metrics
+	l7Stats        L7Stats
+	inboundL7Stats InboundL7Stats
+	dnsStats       *L7Metrics
+	dnsCnames      map[string]string // canonical name -> originally queried name
+	dnsSearchPath  *dnsSearchPath
 
 	gpuStats map[string]*GpuUsage
 
@@ -181,8 +210,11 @@ func NewContainer(id ContainerID, cg *cgroup.Cgroup, md *ContainerMetadata, pid
 		lastConnectionAttempts:   map[common.HostPort]time.Time{},
 		activeConnections:        map[ConnectionKey]*ActiveConnection{},
 		connectionsByPidFd:       map[PidFd]*ActiveConnection{},
+		inboundConnections:       map[PidFd]*InboundConnection{},
 		l7Stats:                  L7Stats{},
+		inboundL7Stats:           InboundL7Stats{},
 		dnsStats:                 &L7Metrics{},
+		dnsCnames:                map[string]string{},
 
 		gpuStats: map[string]*GpuUsage{},
 
@@ -202,12 +234,18 @@ func NewContainer(id ContainerID, cg *cgroup.Cgroup, md *ContainerMetadata, pid
 	go func() {
 		ticker := time.NewTicker(gcInterval)
 		defer ticker.Stop()
+		dnsTicker := time.NewTicker(dnsSearchPathTimeout)
+		defer dnsTicker.Stop()
 		for {
 			select {
 			case <-c.done:
 				return
 			case t := <-ticker.C:
 				c.gc(t)
+			case t := <-dnsTicker.C:
+				c.lock.Lock()
+				c.countExpiredDnsSearchPathMisses(t)
+				c.lock.Unlock()
 			}
 		}
 	}()
@@ -410,7 +448,11 @@ func (c *Container) Collect(ch chan<- prometheus.Metric) {
 	if c.dnsStats.Latency != nil {
 		c.dnsStats.Latency.Collect(ch)
 	}
+	if c.dnsStats.SearchPathMisses != nil {
+		c.dnsStats.SearchPathMisses.Collect(ch)
+	}
 	c.l7Stats.collect(ch)
+	c.inboundL7Stats.collect(ch)
 
 	if !*flags.DisablePinger {
 		for ip, rtt := range c.ping() {
@@ -608,6 +650,40 @@ func (c *Container) onConnectionOpen(pid uint32, fd uint64, src, dst, actualDst
 	c.lastConnectionAttempts[key.Destination()] = time.Now()
 }
 
+func (c *Container) onConnectionAccept(pid uint32, fd uint64, listenAddr, clientAddr netaddr.IPPort, timestamp uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.processes[pid] == nil {
+		return
+	}
+	if !c.isListening(listenAddr) {
+		return
+	}
+	k := PidFd{Pid: pid, Fd: fd}
+	if prev := c.inboundConnections[k]; prev != nil {
+		prev.Closed = time.Now()
+	}
+	c.inboundConnections[k] = &InboundConnection{
+		ListenAddr: listenAddr,
+		ClientAddr: clientAddr,
+		Pid:        pid,
+		Fd:         fd,
+		Timestamp:  timestamp,
+	}
+}
+
+func (c *Container) isListening(addr netaddr.IPPort) bool {
+	if _, ok := c.listens[addr]; ok {
+		return true
+	}
+	for a := range c.listens {
+		if a.Port() == addr.Port() && a.IP().IsUnspecified() {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Container) onConnectionClose(e ebpftracer.Event) {
 	c.lock.Lock()
 	conn := c.connectionsByPidFd[PidFd{Pid: e.Pid, Fd: e.Fd}]
@@ -655,16 +731,17 @@ func (c *Container) updateConnectionTrafficStats(ac *ActiveConnection, sent, rec
 	ac.BytesReceived = received
 }
 
-func (c *Container) onDNSRequest(r *l7.RequestData) map[netaddr.IP]*common.Domain {
+func (c *Container) onDNSRequest(pid uint32, r *l7.RequestData) map[netaddr.IP]*common.Domain {
 	status := r.Status.DNS()
 	if status == "" {
 		return nil
 	}
-	t, fqdn, ips := l7.ParseDns(r.Payload)
-	if t == "" {
+	resp := l7.ParseDns(r.Payload)
+	if resp == nil {
 		return nil
 	}
-	fqdn = common.NormalizeFQDN(fqdn, t)
+	t, ips := resp.Type, resp.IPs
+	fqdn := common.NormalizeFQDN(resp.Name, t)
 
 	// To reduce the number of metrics, we ignore AAAA requests with empty results,
 	// as they are typically performed simultaneously with A requests and do not add
@@ -680,19 +757,55 @@ func (c *Container) onDNSRequest(r *l7.RequestData) map[netaddr.IP]*common.Domai
 			[]string{"request_type", "domain", "status"},
 		)
 	}
-	if m, _ := c.dnsStats.Requests.GetMetricWithLabelValues(t, fqdn, status); m != nil {
-		m.Inc()
-	}
 	if r.Duration != 0 {
 		if c.dnsStats.Latency == nil {
 			dnsLatency := L7Latency[l7.ProtocolDNS]
-			c.dnsStats.Latency = prometheus.NewHistogram(prometheus.HistogramOpts{Name: dnsLatency.Name, Help: dnsLatency.Help})
+			c.dnsStats.Latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: dnsLatency.Name, Help: dnsLatency.Help}, nil)
+		}
+		c.dnsStats.Latency.WithLabelValues().Observe(r.Duration.Seconds())
+	}
+
+	// NXDOMAIN responses to the names expanded with the search domains (ndots) are counted as search path misses
+	// of the name that ends the sequence. If the sequence has not ended in time, they are counted as failed requests.
+	now := time.Now()
+	if c.dnsSearchPath == nil {
+		c.dnsSearchPath = &dnsSearchPath{}
+	}
+	c.dnsSearchPath.update(pid, now)
+	c.countExpiredDnsSearchPathMisses(now)
+	if status == "nxdomain" && c.dnsSearchPath.miss(t, resp.Name, now) {
+		return nil
+	}
+	if misses := c.dnsSearchPath.resolve(t, resp.Name); misses > 0 {
+		if c.dnsStats.SearchPathMisses == nil {
+			c.dnsStats.SearchPathMisses = prometheus.NewCounterVec(
+				prometheus.CounterOpts{Name: DnsSearchPathMisses.Name, Help: DnsSearchPathMisses.Help},
+				[]string{"request_type", "domain"},
+			)
+		}
+		if m, _ := c.dnsStats.SearchPathMisses.GetMetricWithLabelValues(t, c.dnsStats.limitLabelValue("domain", fqdn)); m != nil {
+			m.Add(float64(misses))
+		}
+	}
+	c.dnsStats.inc(t, c.dnsStats.limitLabelValue("domain", fqdn), status)
+
+	// If the name has been received as a CNAME in response to a previous request (e.g., the resolver follows
+	// the chain itself), the IPs are mapped to the originally queried name rather than to the canonical one.
+	name := resp.Name
+	if orig, ok := c.dnsCnames[name]; ok {
+		name = orig
+	}
+	if len(resp.Cnames) > 0 {
+		if len(c.dnsCnames) >= dnsMaxTrackedCnames {
+			c.dnsCnames = map[string]string{}
+		}
+		for _, cname := range resp.Cnames {
+			c.dnsCnames[cname] = name
 		}
-		c.dnsStats.Latency.Observe(r.Duration.Seconds())
 	}
 	ip2fqdn := map[netaddr.IP]*common.Domain{}
-	if fqdn != "" {
-		d := common.NewDomain(fqdn, ips)
+	if name != "" {
+		d := common.NewDomain(common.NormalizeFQDN(name, t), ips)
 		for _, ip := range ips {
 			ip2fqdn[ip] = d
 		}
@@ -700,12 +813,38 @@ func (c *Container) onDNSRequest(r *l7.RequestData) map[netaddr.IP]*common.Domai
 	return ip2fqdn
 }
 
+// countExpiredDnsSearchPathMisses counts the pending NXDOMAIN responses whose sequences have not ended in time as failed requests
+func (c *Container) countExpiredDnsSearchPathMisses(now time.Time) {
+	if c.dnsSearchPath == nil {
+		return
+	}
+	for _, m := range c.dnsSearchPath.expired(now) {
+		c.dnsStats.inc(m.requestType, c.dnsStats.limitLabelValue("domain", common.NormalizeFQDN(m.name, m.requestType)), "nxdomain")
+	}
+}
+
 func (c *Container) onL7Request(pid uint32, fd uint64, timestamp uint64, r *l7.RequestData) map[netaddr.IP]*common.Domain {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	if r.Protocol == l7.ProtocolDNS {
-		return c.onDNSRequest(r)
+		return c.onDNSRequest(pid, r)
+	}
+
+	if r.Inbound {
+		conn := c.inboundConnections[PidFd{Pid: pid, Fd: fd}]
+		if conn == nil {
+			return nil
+		}
+		if timestamp != 0 && conn.Timestamp != timestamp {
+			return nil
+		}
+		getStats := func(protocol l7.Protocol) *L7Metrics {
+			return c.inboundL7Stats.get(protocol, conn.ListenAddr)
+		}
+		trace := c.tracer.NewServerTrace(common.HostPortFromIPPort(conn.ListenAddr), common.HostPortFromIPPort(conn.ClientAddr))
+		c.handleL7Request(&conn.l7Parsers, getStats, trace, r)
+		return nil
 	}
 
 	conn := c.connectionsByPidFd[PidFd{Pid: pid, Fd: fd}]
@@ -715,73 +854,244 @@ func (c *Container) onL7Request(pid uint32, fd uint64, timestamp uint64, r *l7.R
 	if timestamp != 0 && conn.Timestamp != timestamp {
 		return nil
 	}
-	stats := c.l7Stats.get(r.Protocol, conn.DestinationKey)
-
+	getStats := func(protocol l7.Protocol) *L7Metrics {
+		return c.l7Stats.get(protocol, conn.DestinationKey)
+	}
 	trace := c.tracer.NewTrace(conn.DestinationKey.ActualDestinationIfKnown())
+	c.handleL7Request(&conn.l7Parsers, getStats, trace, r)
+	return nil
+}
+
+func (c *Container) handleL7Request(parsers *l7Parsers, getStats func(protocol l7.Protocol) *L7Metrics, trace *tracing.Trace, r *l7.RequestData) {
+	stats := getStats(r.Protocol)
+	trace = trace.WithEndTime(common.KernelTimeToTime(r.KernelTime))
 	switch r.Protocol {
 	case l7.ProtocolHTTP:
 		method, path := l7.ParseHttp(r.Payload)
-		if !common.HttpFilter.ShouldBeSkipped(path) {
-			stats.observe(r.Status.Http(), "", r.Duration)
-			trace.HttpRequest(method, path, r.Status, r.Duration)
+		if common.HttpFilter.ShouldBeSkipped(path) {
+			return
+		}
+		trace = trace.WithParent(l7.ParseHttpTraceContext(r.Payload))
+		var cloud *l7.CloudRequest
+		if !r.Inbound {
+			cloud = l7.ParseCloudHttp(r.Payload)
+		}
+		graphql := l7.ParseGraphql(r.Payload)
+		route := common.HttpRouter.Route(path)
+		stats.observe(r.Status.Http(), r.Duration, stats.httpLabelValues(method, route, cloud, graphql)...)
+		// Elasticsearch requests are reported both as HTTP requests and as Elasticsearch operations
+		if req := l7.ParseElasticsearch(method, path); req != nil {
+			esStats := getStats(l7.ProtocolElasticsearch)
+			esStats.inc(r.Status.Http(), req.Operation, esStats.limitLabelValue("index", req.Index))
+			esStats.observeLatency(r.Duration)
+			trace.ElasticsearchRequest(req, method, path, r.Status, r.Duration)
+			return
 		}
+		trace.HttpRequest(method, path, route, cloud, graphql, r.Status, r.Duration)
 	case l7.ProtocolHTTP2:
-		if conn.http2Parser == nil {
-			conn.http2Parser = l7.NewHttp2Parser()
+		if parsers.http2Parser == nil {
+			parsers.http2Parser = l7.NewHttp2Parser()
 		}
-		requests := conn.http2Parser.Parse(r.Method, r.Payload, uint64(r.Duration))
+		requests := parsers.http2Parser.Parse(r.Method, r.Payload, uint64(r.Duration))
 		for _, req := range requests {
-			if !common.HttpFilter.ShouldBeSkipped(req.Path) {
-				stats.observe(req.Status.Http(), "", req.Duration)
-				trace.Http2Request(req.Method, req.Path, req.Scheme, req.Status, req.Duration)
+			if common.HttpFilter.ShouldBeSkipped(req.Path) {
+				continue
+			}
+			if req.Grpc {
+				service, method := l7.ParseGrpcPath(req.Path)
+				grpcStats := getStats(l7.ProtocolGrpc)
+				grpcStats.inc(grpcStats.limitLabelValue("service", service), grpcStats.limitLabelValue("method", method), req.GrpcStatus.String())
+				grpcStats.observeLatency(req.Duration)
+				trace.WithParent(req.TraceContext()).GrpcRequest(service, method, req.GrpcStatus, req.Duration)
+				continue
+			}
+			var cloud *l7.CloudRequest
+			if !r.Inbound {
+				cloud = l7.ParseCloudRequest(req.Method, req.Authority, req.Path, "", nil)
 			}
+			route := common.HttpRouter.Route(req.Path)
+			stats.observe(req.Status.Http(), req.Duration, stats.httpLabelValues(req.Method, route, cloud, nil)...)
+			trace.WithParent(req.TraceContext()).Http2Request(req.Method, req.Path, route, req.Scheme, cloud, req.Status, req.Duration)
+		}
+	case l7.ProtocolFastcgi:
+		if parsers.fastcgiParser == nil {
+			parsers.fastcgiParser = l7.NewFastcgiParser()
+		}
+		if r.Method == l7.MethodResponse {
+			parsers.fastcgiParser.ParseResponse(r.Payload)
+			return
+		}
+		status := parsers.fastcgiParser.Status(r.Status)
+		req := l7.ParseFastcgi(r.Payload)
+		if !common.HttpFilter.ShouldBeSkipped(req.Path()) {
+			route := common.HttpRouter.Route(req.Path())
+			stats.observe(status.Http(), r.Duration, stats.httpRouteLabelValues(req.Method, route)...)
+			trace.FastcgiRequest(req, route, status, r.Duration)
 		}
 	case l7.ProtocolPostgres:
-		if r.Method != l7.MethodStatementClose {
-			stats.observe(r.Status.String(), "", r.Duration)
+		if parsers.postgresParser == nil {
+			parsers.postgresParser = l7.NewPostgresParser()
 		}
-		if conn.postgresParser == nil {
-			conn.postgresParser = l7.NewPostgresParser()
+		if r.Method == l7.MethodErrorResponse {
+			parsers.postgresParser.ParseErrorResponse(r.Payload)
+			return
 		}
-		query := conn.postgresParser.Parse(r.Payload)
-		trace.PostgresQuery(query, r.Status.Error(), r.Duration)
-	case l7.ProtocolMysql:
+		var pgErr *l7.PostgresError
+		if r.Status.Error() {
+			pgErr = parsers.postgresParser.Error()
+		}
+		query := parsers.postgresParser.Parse(r.Payload)
 		if r.Method != l7.MethodStatementClose {
-			stats.observe(r.Status.String(), "", r.Duration)
+			stats.observe(r.Status.String(), r.Duration, pgErr.Class())
+			stats.statements.observe(query, r.Status.Error(), r.Duration)
+			end := common.KernelTimeToTime(r.KernelTime)
+			if end.IsZero() {
+				end = time.Now()
+			}
+			stats.observeTransaction(parsers.postgresParser.Transaction(query, r.Status.Error(), end.Add(-r.Duration), end))
 		}
-		if conn.mysqlParser == nil {
-			conn.mysqlParser = l7.NewMysqlParser()
+		trace.PostgresQuery(query, pgErr, r.Status.Error(), r.Duration)
+	case l7.ProtocolMysql:
+		if parsers.mysqlParser == nil {
+			parsers.mysqlParser = l7.NewMysqlParser()
+		}
+		query := parsers.mysqlParser.Parse(r.Payload, r.StatementId)
+		if r.Method != l7.MethodStatementClose {
+			stats.observe(r.Status.String(), r.Duration)
+			stats.statements.observe(query, r.Status.Error(), r.Duration)
 		}
-		query := conn.mysqlParser.Parse(r.Payload, r.StatementId)
 		trace.MysqlQuery(query, r.Status.Error(), r.Duration)
 	case l7.ProtocolMemcached:
-		stats.observe(r.Status.String(), "", r.Duration)
+		if parsers.memcachedParser == nil {
+			parsers.memcachedParser = l7.NewMemcachedParser()
+		}
+		if r.Method == l7.MethodErrorResponse {
+			parsers.memcachedParser.ParseErrorResponse(r.Payload)
+			return
+		}
+		status, errMsg := parsers.memcachedParser.Status(r.Status)
+		stats.observe(status, r.Duration)
 		cmd, items := l7.ParseMemcached(r.Payload)
-		trace.MemcachedQuery(cmd, items, r.Status.Error(), r.Duration)
+		trace.MemcachedQuery(cmd, items, status, errMsg, r.Status.Error(), r.Duration)
 	case l7.ProtocolRedis:
-		stats.observe(r.Status.String(), "", r.Duration)
-		cmd, args := l7.ParseRedis(r.Payload)
-		trace.RedisQuery(cmd, args, r.Status.Error(), r.Duration)
+		if parsers.redisParser == nil {
+			parsers.redisParser = l7.NewRedisParser()
+		}
+		if r.Method == l7.MethodErrorResponse {
+			parsers.redisParser.ParseErrorResponse(r.Payload)
+			return
+		}
+		status, redisErr := parsers.redisParser.Status(r.Status)
+		commands := l7.ParseRedisPipeline(r.Payload)
+		// the replies to pipelined commands are received together, so a pipeline is counted as a single request
+		var cmd string
+		switch {
+		case len(commands) == 1:
+			cmd = commands[0].Cmd
+		case len(commands) > 1:
+			cmd = "pipeline"
+		}
+		stats.observe(status, r.Duration, stats.redisCommandLabelValues(cmd)...)
+		trace.RedisQuery(commands, redisErr, r.Status.Error(), r.Duration)
 	case l7.ProtocolMongo:
-		stats.observe(r.Status.String(), "", r.Duration)
+		if parsers.mongoParser == nil {
+			parsers.mongoParser = l7.NewMongoParser()
+		}
+		if r.Method == l7.MethodErrorResponse {
+			parsers.mongoParser.ParseReply(r.Payload)
+			return
+		}
+		status := r.Status
+		reply := parsers.mongoParser.Reply()
+		if reply != nil && !reply.Ok {
+			status = l7.StatusFailed
+		}
+		cmd := l7.ParseMongoCommand(r.Payload)
+		stats.observe(status.String(), r.Duration, stats.mongoCommandLabelValues(cmd)...)
 		query := l7.ParseMongo(r.Payload)
-		trace.MongoQuery(query, r.Status.Error(), r.Duration)
-	case l7.ProtocolKafka, l7.ProtocolCassandra:
-		stats.observe(r.Status.String(), "", r.Duration)
-	case l7.ProtocolRabbitmq, l7.ProtocolNats:
-		stats.observe(r.Status.String(), r.Method.String(), 0)
+		trace.MongoQuery(query, cmd, reply, status.Error(), r.Duration)
+	case l7.ProtocolKafka:
+		req := l7.ParseKafka(r.Payload)
+		op := req.Operation()
+		if req == nil || len(req.Topics) == 0 {
+			stats.inc(r.Status.String(), op, "")
+		} else {
+			for _, topic := range req.Topics {
+				stats.inc(r.Status.String(), op, stats.limitLabelValue("topic", topic))
+			}
+		}
+		stats.observeLatency(r.Duration)
+		trace.KafkaRequest(req, r.Status.Error(), r.Duration)
+	case l7.ProtocolCassandra:
+		stats.observe(r.Status.String(), r.Duration)
+		if parsers.cassandraParser == nil {
+			parsers.cassandraParser = l7.NewCassandraParser()
+		}
+		query, keyspace := parsers.cassandraParser.Parse(r.Payload, r.StatementId)
+		trace.CassandraQuery(query, keyspace, r.Status.Error(), r.Duration)
+	case l7.ProtocolRabbitmq:
+		exchange, routingKey := l7.ParseRabbitmq(r.Payload)
+		stats.inc(r.Status.String(), r.Method.String(), stats.limitLabelValue("exchange", exchange), stats.limitLabelValue("routing_key", routingKey))
+		trace.RabbitmqMessage(r.Method, exchange, routingKey, r.Status.Error())
+	case l7.ProtocolNats:
+		subject := l7.ParseNats(r.Payload)
+		stats.inc(r.Status.String(), r.Method.String(), stats.limitLabelValue("subject", subject))
+		trace.NatsMessage(r.Method, subject, r.Status.Error())
+	case l7.ProtocolMqtt:
+		if parsers.mqttParser == nil {
+			parsers.mqttParser = l7.NewMqttParser()
+		}
+		for _, req := range parsers.mqttParser.Parse(r.Method, r.Payload, uint64(r.Duration)) {
+			if req.Method != l7.MethodUnknown {
+				stats.inc(req.Status, req.Method.String(), stats.limitLabelValue("topic", req.Topics[0]))
+			}
+			trace.MqttRequest(req)
+		}
+	case l7.ProtocolPulsar:
+		if parsers.pulsarParser == nil {
+			parsers.pulsarParser = l7.NewPulsarParser()
+		}
+		for _, req := range parsers.pulsarParser.Parse(r.Method, r.Payload, uint64(r.Duration)) {
+			if op := req.Operation(); op != "" {
+				stats.add(float64(req.Messages), req.Status, op, stats.limitLabelValue("topic", req.Topic))
+			}
+			if req.Command == "SEND" {
+				stats.observeLatency(req.Duration)
+			}
+			trace.PulsarRequest(req)
+		}
 	case l7.ProtocolDubbo2:
-		stats.observe(r.Status.String(), "", r.Duration)
+		dubboStatus := l7.Dubbo2Status(r.StatementId)
+		stats.inc(r.Status.String(), dubboStatus.String())
+		stats.observeLatency(r.Duration)
+		service, method := l7.ParseDubbo2(r.Payload)
+		trace.Dubbo2Request(service, method, r.Status, dubboStatus, r.Duration)
 	case l7.ProtocolClickhouse:
-		stats.observe(r.Status.String(), "", r.Duration)
+		stats.observe(r.Status.String(), r.Duration)
 		query := l7.ParseClickhouse(r.Payload)
+		stats.statements.observe(query, r.Status.Error(), r.Duration)
 		trace.ClickhouseQuery(query, r.Status.Error(), r.Duration)
+	case l7.ProtocolMssql:
+		if parsers.mssqlParser == nil {
+			parsers.mssqlParser = l7.NewMssqlParser()
+		}
+		if r.Method == l7.MethodErrorResponse {
+			parsers.mssqlParser.ParseErrorResponse(r.Payload)
+			return
+		}
+		var mssqlErr *l7.MssqlError
+		if r.Status.Error() {
+			mssqlErr = parsers.mssqlParser.Error()
+		}
+		stats.observe(r.Status.String(), r.Duration)
+		query := l7.ParseMssql(r.Payload)
+		stats.statements.observe(query, r.Status.Error(), r.Duration)
+		trace.MssqlQuery(query, mssqlErr, r.Status.Error(), r.Duration)
 	case l7.ProtocolZookeeper:
-		stats.observe(r.Status.Zookeeper(), "", r.Duration)
+		stats.observe(r.Status.Zookeeper(), r.Duration)
 		op, arg := l7.ParseZookeeper(r.Payload)
 		trace.ZookeeperRequest(op, arg, r.Status, r.Duration)
 	}
-	return nil
 }
 
 func (c *Container) onRetransmission(src netaddr.IPPort, dst netaddr.IPPort) bool {
@@ -1083,6 +1393,8 @@ func (c *Container) gc(now time.Time) {
 			}
 		}
 	}
+	c.gcInboundConnections(now, established)
+
 	for dst, at := range c.lastConnectionAttempts {
 		_, active := establishedDst[dst]
 		if !active && !at.IsZero() && now.Sub(at) > gcInterval {
@@ -1098,6 +1410,19 @@ func (c *Container) gc(now time.Time) {
 	}
 }
 
+// gcInboundConnections removes the accepted connections that are no longer established
+func (c *Container) gcInboundConnections(now time.Time, established map[ConnectionKey]struct{}) {
+	for k, conn := range c.inboundConnections {
+		if _, ok := established[ConnectionKey{src: conn.ListenAddr, dst: conn.ClientAddr}]; !ok {
+			delete(c.inboundConnections, k)
+			continue
+		}
+		if !conn.Closed.IsZero() && now.Sub(conn.Closed) > gcInterval {
+			delete(c.inboundConnections, k)
+		}
+	}
+}
+
 func (c *Container) revalidateListens(now time.Time, actualListens map[netaddr.IPPort]string) {
 	for addr, byPid := range c.listens {
 		if _, open := actualListens[addr]; open {
@@ -1162,6 +1487,7 @@ func (c *Container) revalidateListens(now time.Time, actualListens map[netaddr.I
 		}
 		if len(c.listens[addr]) == 0 {
 			delete(c.listens, addr)
+			c.inboundL7Stats.delete(addr)
 		}
 	}
 }
@@ -0,0 +1,117 @@
+package containers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coroot/coroot-node-agent/ebpftracer/l7"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"inet.af/netaddr"
+)
+
+func newTestContainer() *Container {
+	return &Container{
+		processes:          map[uint32]*Process{},
+		listens:            map[netaddr.IPPort]map[uint32]*ListenDetails{},
+		inboundConnections: map[PidFd]*InboundConnection{},
+		inboundL7Stats:     InboundL7Stats{},
+	}
+}
+
+func TestOnConnectionAccept(t *testing.T) {
+	c := newTestContainer()
+	c.processes[1] = &Process{Pid: 1}
+	c.listens[netaddr.MustParseIPPort("0.0.0.0:80")] = map[uint32]*ListenDetails{1: {}}
+	c.listens[netaddr.MustParseIPPort("10.0.0.1:443")] = map[uint32]*ListenDetails{1: {}}
+	client := netaddr.MustParseIPPort("10.0.0.2:50000")
+
+	c.onConnectionAccept(2, 3, netaddr.MustParseIPPort("10.0.0.1:80"), client, 100)   // unknown process
+	c.onConnectionAccept(1, 3, netaddr.MustParseIPPort("10.0.0.1:8080"), client, 100) // not listening
+	c.onConnectionAccept(1, 3, netaddr.MustParseIPPort("10.0.0.3:443"), client, 100)  // listening on another IP
+	assert.Empty(t, c.inboundConnections)
+
+	c.onConnectionAccept(1, 3, netaddr.MustParseIPPort("10.0.0.1:80"), client, 100) // wildcard listen
+	c.onConnectionAccept(1, 4, netaddr.MustParseIPPort("10.0.0.1:443"), client, 100)
+	assert.Len(t, c.inboundConnections, 2)
+	conn := c.inboundConnections[PidFd{Pid: 1, Fd: 3}]
+	assert.Equal(t, netaddr.MustParseIPPort("10.0.0.1:80"), conn.ListenAddr)
+	assert.Equal(t, client, conn.ClientAddr)
+	assert.Equal(t, uint64(100), conn.Timestamp)
+
+	// the fd is reused for a new connection
+	c.onConnectionAccept(1, 3, netaddr.MustParseIPPort("10.0.0.1:80"), netaddr.MustParseIPPort("10.0.0.2:50001"), 200)
+	assert.False(t, conn.Closed.IsZero())
+	assert.Equal(t, uint64(200), c.inboundConnections[PidFd{Pid: 1, Fd: 3}].Timestamp)
+}
+
+func TestGcInboundConnections(t *testing.T) {
+	c := newTestContainer()
+	now := time.Now()
+	listen := netaddr.MustParseIPPort("10.0.0.1:80")
+	c1 := netaddr.MustParseIPPort("10.0.0.2:50000")
+	c2 := netaddr.MustParseIPPort("10.0.0.2:50001")
+	c3 := netaddr.MustParseIPPort("10.0.0.2:50002")
+	c4 := netaddr.MustParseIPPort("10.0.0.2:50003")
+	c.inboundConnections[PidFd{Pid: 1, Fd: 1}] = &InboundConnection{ListenAddr: listen, ClientAddr: c1}
+	c.inboundConnections[PidFd{Pid: 1, Fd: 2}] = &InboundConnection{ListenAddr: listen, ClientAddr: c2}
+	c.inboundConnections[PidFd{Pid: 1, Fd: 3}] = &InboundConnection{ListenAddr: listen, ClientAddr: c3, Closed: now.Add(-2 * gcInterval)}
+	c.inboundConnections[PidFd{Pid: 1, Fd: 4}] = &InboundConnection{ListenAddr: listen, ClientAddr: c4, Closed: now.Add(-time.Minute)}
+
+	c.gcInboundConnections(now, map[ConnectionKey]struct{}{
+		{src: listen, dst: c1}: {},
+		{src: listen, dst: c3}: {},
+		{src: listen, dst: c4}: {},
+	})
+	assert.Len(t, c.inboundConnections, 2)
+	assert.NotNil(t, c.inboundConnections[PidFd{Pid: 1, Fd: 1}]) // established
+	assert.Nil(t, c.inboundConnections[PidFd{Pid: 1, Fd: 2}])    // not established anymore
+	assert.Nil(t, c.inboundConnections[PidFd{Pid: 1, Fd: 3}])    // closed long ago
+	assert.NotNil(t, c.inboundConnections[PidFd{Pid: 1, Fd: 4}]) // closed recently
+}
+
+func TestInboundL7Stats(t *testing.T) {
+	s := InboundL7Stats{}
+	a1 := netaddr.MustParseIPPort("10.0.0.1:80")
+	a2 := netaddr.MustParseIPPort("10.0.0.2:80")
+	a3 := netaddr.MustParseIPPort("10.0.0.1:3306")
+
+	http := s.get(l7.ProtocolHTTP, a1)
+	assert.Same(t, http, s.get(l7.ProtocolHTTP, a1))
+	assert.Same(t, http, s.get(l7.ProtocolHTTP2, a1)) // HTTP/2 requests are reported as HTTP
+	http.observe("200", 10*time.Millisecond)
+	http.observe("500", 20*time.Millisecond)
+	s.get(l7.ProtocolHTTP, a2).observe("200", 10*time.Millisecond)
+	s.get(l7.ProtocolMysql, a3).observe("ok", time.Millisecond)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(http.Requests, "container_http_inbound_requests_total"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(http.Requests.WithLabelValues("500")))
+	assert.Equal(t, 1, testutil.CollectAndCount(http.Latency, "container_http_inbound_requests_duration_seconds_total"))
+	assert.Equal(t, 7, collectCount(s)) // 4 counters + 3 histograms
+
+	s.delete(netaddr.MustParseIPPort("0.0.0.0:80")) // a wildcard listen covers all IPs
+	assert.Empty(t, s[l7.ProtocolHTTP])
+	assert.Len(t, s[l7.ProtocolMysql], 1)
+
+	s.get(l7.ProtocolHTTP, a1)
+	s.get(l7.ProtocolHTTP, a2)
+	s.delete(a1)
+	assert.Len(t, s[l7.ProtocolHTTP], 1)
+	assert.NotNil(t, s[l7.ProtocolHTTP][a2])
+}
+
+func collectCount(c interface {
+	collect(chan<- prometheus.Metric)
+}) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.collect(ch)
+		close(ch)
+	}()
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
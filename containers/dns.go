@@ -0,0 +1,162 @@
+package containers
+
+import (
+	"bufio"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coroot/coroot-node-agent/common"
+	"github.com/coroot/coroot-node-agent/proc"
+	"k8s.io/klog/v2"
+)
+
+const (
+	dnsSearchPathTimeout          = 5 * time.Second
+	dnsSearchPathMaxPending       = 1000
+	dnsSearchDomainsCheckInterval = 30 * time.Second
+)
+
+// dnsSearchPath recognizes the sequences of queries made by resolvers for names having fewer dots than ndots:
+// e.g., with ndots:5, api.example.com is queried as api.example.com.default.svc.cluster.local,
+// api.example.com.svc.cluster.local, api.example.com.cluster.local and only then as api.example.com.
+// The NXDOMAIN responses to the expanded names are kept pending until the query that ends the sequence is observed.
+// The pending responses that have not been followed by such a query in time are considered real failures.
+type dnsSearchPath struct {
+	domains []string
+	pending []dnsSearchPathMiss
+
+	pid       uint32    // the process whose resolv.conf the domains have been read from
+	modTime   time.Time // the modification time of that resolv.conf
+	checkedAt time.Time
+}
+
+type dnsSearchPathMiss struct {
+	requestType string
+	name        string
+	bases       []string // the names that could have been expanded into the queried one
+	time        time.Time
+}
+
+// update re-reads the search domains if the request has been made by another process or if its resolv.conf has changed.
+// The modification time of the file is checked at most once per dnsSearchDomainsCheckInterval.
+func (sp *dnsSearchPath) update(pid uint32, now time.Time) {
+	if pid == sp.pid && now.Sub(sp.checkedAt) < dnsSearchDomainsCheckInterval {
+		return
+	}
+	sp.checkedAt = now
+	path := proc.Path(pid, "root/etc/resolv.conf")
+	info, err := os.Stat(path)
+	if err != nil {
+		if !common.IsNotExist(err) {
+			klog.Warningln(err)
+		}
+		sp.pid, sp.modTime, sp.domains = pid, time.Time{}, nil
+		return
+	}
+	if pid == sp.pid && info.ModTime().Equal(sp.modTime) {
+		return
+	}
+	sp.pid, sp.modTime = pid, info.ModTime()
+	sp.domains = readDnsSearchDomains(path)
+}
+
+// readDnsSearchDomains reads the search list from resolv.conf (the last search or domain directive wins)
+func readDnsSearchDomains(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		if !common.IsNotExist(err) {
+			klog.Warningln(err)
+		}
+		return nil
+	}
+	defer f.Close()
+	var domains []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "search", "domain":
+			domains = domains[:0]
+			for _, d := range fields[1:] {
+				if d = strings.ToLower(strings.Trim(d, ".")); d != "" {
+					domains = append(domains, d)
+				}
+			}
+		}
+	}
+	return domains
+}
+
+// bases returns the names that expand into the given one with one of the search domains
+func (sp *dnsSearchPath) bases(name string) []string {
+	var res []string
+	name = strings.ToLower(name)
+	for _, d := range sp.domains {
+		if base, ok := strings.CutSuffix(name, "."+d); ok && base != "" {
+			res = append(res, base)
+		}
+	}
+	return res
+}
+
+// ends reports whether the name ends a sequence, i.e. whether its expanded versions have been queried before
+func (sp *dnsSearchPath) ends(requestType, name string) bool {
+	name = strings.ToLower(name)
+	for _, m := range sp.pending {
+		if m.requestType == requestType && slices.Contains(m.bases, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// miss records the NXDOMAIN response to the query of the expanded name.
+// It returns false if the name is not an expansion of another one or if it ends a sequence.
+func (sp *dnsSearchPath) miss(requestType, name string, now time.Time) bool {
+	if len(sp.domains) == 0 || len(sp.pending) >= dnsSearchPathMaxPending {
+		return false
+	}
+	bases := sp.bases(name)
+	if len(bases) == 0 || sp.ends(requestType, name) {
+		return false
+	}
+	sp.pending = append(sp.pending, dnsSearchPathMiss{requestType: requestType, name: name, bases: bases, time: now})
+	return true
+}
+
+// resolve removes the pending responses that belong to the sequence ended by the query of the given name
+// and returns their number
+func (sp *dnsSearchPath) resolve(requestType, name string) int {
+	if len(sp.pending) == 0 {
+		return 0
+	}
+	names := append(sp.bases(name), strings.ToLower(name))
+	n := len(sp.pending)
+	sp.pending = slices.DeleteFunc(sp.pending, func(m dnsSearchPathMiss) bool {
+		if m.requestType != requestType {
+			return false
+		}
+		for _, b := range m.bases {
+			if slices.Contains(names, b) {
+				return true
+			}
+		}
+		return false
+	})
+	return n - len(sp.pending)
+}
+
+// expired removes and returns the pending responses that have not been followed by the end of their sequence in time
+func (sp *dnsSearchPath) expired(now time.Time) []dnsSearchPathMiss {
+	var res []dnsSearchPathMiss
+	for len(sp.pending) > 0 && now.Sub(sp.pending[0].time) > dnsSearchPathTimeout {
+		res = append(res, sp.pending[0])
+		sp.pending = sp.pending[1:]
+	}
+	return res
+}
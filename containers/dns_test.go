@@ -0,0 +1,84 @@
+package containers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDnsSearchDomains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	assert.Nil(t, readDnsSearchDomains(path))
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+nameserver 10.96.0.10
+domain example.com
+search default.svc.cluster.local. svc.cluster.local Cluster.Local
+options ndots:5
+`), 0644))
+	assert.Equal(t, []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"}, readDnsSearchDomains(path))
+}
+
+func TestDnsSearchPath(t *testing.T) {
+	sp := &dnsSearchPath{domains: []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"}}
+
+	for _, c := range []struct {
+		name  string
+		bases []string
+	}{
+		{name: "api.example.com", bases: nil},
+		{name: "cluster.local", bases: nil},
+		{name: "api.example.com.cluster.local", bases: []string{"api.example.com"}},
+		{name: "API.example.com.svc.cluster.local", bases: []string{"api.example.com", "api.example.com.svc"}},
+		{name: "db.default.svc.cluster.local", bases: []string{"db", "db.default", "db.default.svc"}},
+	} {
+		assert.Equal(t, c.bases, sp.bases(c.name), c.name)
+	}
+
+	now := time.Now()
+	assert.True(t, sp.miss("TypeA", "api.example.com.default.svc.cluster.local", now))
+	assert.True(t, sp.miss("TypeA", "api.example.com.svc.cluster.local", now))
+	assert.True(t, sp.miss("TypeAAAA", "api.example.com.svc.cluster.local", now))
+	assert.False(t, sp.miss("TypeA", "api.example.org", now))                             // not an expansion
+	assert.False(t, sp.miss("TypeA", "api.example.com.default.svc.cluster.local.x", now)) // not an expansion
+
+	for _, c := range []struct {
+		requestType, name string
+		ends              bool
+	}{
+		{requestType: "TypeA", name: "api.example.com", ends: true},
+		{requestType: "TypeA", name: "API.EXAMPLE.COM", ends: true},
+		{requestType: "TypeAAAA", name: "api.example.com", ends: true},
+		{requestType: "TypeA", name: "api.example.org", ends: false},
+		{requestType: "TypeMX", name: "api.example.com", ends: false},
+	} {
+		assert.Equal(t, c.ends, sp.ends(c.requestType, c.name), c.requestType+" "+c.name)
+	}
+
+	assert.True(t, sp.miss("TypeA", "api.example.com.cluster.local", now.Add(time.Second)))
+	// the NXDOMAIN response to the name ending the sequence is not a search path miss
+	assert.False(t, sp.miss("TypeA", "api.example.com", now.Add(time.Second)))
+
+	assert.Equal(t, 0, sp.resolve("TypeA", "api.example.org"))
+	assert.Equal(t, 3, sp.resolve("TypeA", "api.example.com"))
+	assert.Equal(t, 0, sp.resolve("TypeA", "api.example.com"))
+	assert.Len(t, sp.pending, 1)
+
+	assert.True(t, sp.miss("TypeA", "db.default.svc.cluster.local", now.Add(2*time.Second)))
+	assert.Empty(t, sp.expired(now.Add(dnsSearchPathTimeout)))
+	expired := sp.expired(now.Add(dnsSearchPathTimeout + time.Second))
+	require.Len(t, expired, 1)
+	assert.Equal(t, "TypeAAAA", expired[0].requestType)
+	assert.Equal(t, "api.example.com.svc.cluster.local", expired[0].name)
+	expired = sp.expired(now.Add(time.Minute))
+	require.Len(t, expired, 1)
+	assert.Equal(t, "db.default.svc.cluster.local", expired[0].name)
+	assert.Empty(t, sp.pending)
+
+	sp.domains = nil
+	assert.False(t, sp.miss("TypeA", "api.example.com.cluster.local", now))
+}
@@ -5,33 +5,194 @@ import (
 
 	"github.com/coroot/coroot-node-agent/common"
 	"github.com/coroot/coroot-node-agent/ebpftracer/l7"
+	"github.com/coroot/coroot-node-agent/flags"
 	"github.com/prometheus/client_golang/prometheus"
+	"inet.af/netaddr"
 	"k8s.io/klog/v2"
 )
 
 type L7Metrics struct {
 	Requests *prometheus.CounterVec
-	Latency  prometheus.Histogram
+	Latency  *prometheus.HistogramVec
+
+	// Postgres only
+	Transactions      *prometheus.CounterVec
+	IdleInTransaction prometheus.Counter
+
+	// DNS only
+	SearchPathMisses *prometheus.CounterVec
+
+	statements *statementStats
+
+	labelValues map[string]map[string]struct{} // label -> seen values
+}
+
+// observe increments the request counter and records the latency. The status label is the first one for
+// the counter and is not used for the histogram; the rest of the label values are passed to both.
+func (m *L7Metrics) observe(status string, duration time.Duration, labelValues ...string) {
+	m.inc(append([]string{status}, labelValues...)...)
+	m.observeLatency(duration, labelValues...)
+}
+
+func (m *L7Metrics) inc(labelValues ...string) {
+	m.add(1, labelValues...)
+}
+
+func (m *L7Metrics) add(v float64, labelValues ...string) {
+	if m.Requests == nil {
+		return
+	}
+	c, err := m.Requests.GetMetricWithLabelValues(labelValues...)
+	if err != nil {
+		klog.Warningln(err)
+		return
+	}
+	c.Add(v)
+}
+
+func (m *L7Metrics) observeLatency(duration time.Duration, labelValues ...string) {
+	if m.Latency == nil || duration == 0 {
+		return
+	}
+	h, err := m.Latency.GetMetricWithLabelValues(labelValues...)
+	if err != nil {
+		klog.Warningln(err)
+		return
+	}
+	h.Observe(duration.Seconds())
+}
+
+func (m *L7Metrics) redisCommandLabelValues(cmd string) []string {
+	if !*flags.RedisCommandLabels {
+		return nil
+	}
+	if cmd == "" {
+		cmd = "unknown"
+	}
+	return []string{m.limitLabelValue("command", cmd)}
+}
+
+func (m *L7Metrics) mongoCommandLabelValues(cmd *l7.MongoCommand) []string {
+	if !*flags.MongoCommandLabels {
+		return nil
+	}
+	if cmd == nil {
+		return []string{"unknown", "", ""}
+	}
+	return []string{
+		m.limitLabelValue("command", cmd.Name),
+		m.limitLabelValue("db", cmd.Db),
+		m.limitLabelValue("collection", cmd.Collection),
+	}
+}
+
+func (m *L7Metrics) observeTransaction(outcome string, idle time.Duration) {
+	if m.Transactions == nil {
+		return
+	}
+	if outcome != "" {
+		m.Transactions.WithLabelValues(outcome).Inc()
+	}
+	if idle > 0 {
+		m.IdleInTransaction.Add(idle.Seconds())
+	}
+}
+
+func (m *L7Metrics) httpRouteLabelValues(method, route string) []string {
+	if !*flags.HTTPRouteLabels {
+		return nil
+	}
+	return []string{method, m.limitLabelValue("route", route)}
 }
 
-func (m *L7Metrics) observe(status, method string, duration time.Duration) {
-	if m.Requests != nil {
-		var err error
-		var c prometheus.Counter
-		if method != "" {
-			c, err = m.Requests.GetMetricWithLabelValues(status, method)
+// httpLabelValues returns the values of the optional labels of the HTTP metrics
+func (m *L7Metrics) httpLabelValues(method, route string, cloud *l7.CloudRequest, graphql *l7.GraphqlOperation) []string {
+	values := m.httpRouteLabelValues(method, route)
+	if *flags.CloudAPILabels {
+		if cloud == nil {
+			values = append(values, "", "")
 		} else {
-			c, err = m.Requests.GetMetricWithLabelValues(status)
+			values = append(values, m.limitLabelValue("cloud_service", cloud.Service), m.limitLabelValue("cloud_operation", cloud.Operation))
 		}
-		if err != nil {
-			klog.Warningln(err)
+	}
+	if *flags.GraphqlLabels {
+		if graphql == nil {
+			values = append(values, "", "")
 		} else {
-			c.Inc()
+			values = append(values, graphql.Type, m.limitLabelValue("graphql_operation", graphql.Name))
 		}
 	}
-	if m.Latency != nil && duration != 0 {
-		m.Latency.Observe(duration.Seconds())
+	return values
+}
+
+func (m *L7Metrics) limitLabelValue(label, value string) string {
+	if value == "" {
+		return value
+	}
+	if m.labelValues == nil {
+		m.labelValues = map[string]map[string]struct{}{}
+	}
+	values := m.labelValues[label]
+	if values == nil {
+		values = map[string]struct{}{}
+		m.labelValues[label] = values
+	}
+	if _, ok := values[value]; ok {
+		return value
+	}
+	if len(values) >= *flags.MaxL7LabelValues {
+		return "other"
+	}
+	values[value] = struct{}{}
+	return value
+}
+
+func l7RequestLabels(protocol l7.Protocol) []string {
+	switch protocol {
+	case l7.ProtocolHTTP, l7.ProtocolFastcgi:
+		return append([]string{"status"}, l7LatencyLabels(protocol)...)
+	case l7.ProtocolRabbitmq:
+		return []string{"status", "method", "exchange", "routing_key"}
+	case l7.ProtocolNats:
+		return []string{"status", "method", "subject"}
+	case l7.ProtocolMqtt, l7.ProtocolPulsar:
+		return []string{"status", "method", "topic"}
+	case l7.ProtocolKafka:
+		return []string{"status", "operation", "topic"}
+	case l7.ProtocolGrpc:
+		return []string{"service", "method", "grpc_status"}
+	case l7.ProtocolElasticsearch:
+		return []string{"status", "operation", "index"}
+	case l7.ProtocolPostgres:
+		return []string{"status", "sqlstate"}
+	case l7.ProtocolDubbo2:
+		return []string{"status", "dubbo_status"}
+	case l7.ProtocolRedis, l7.ProtocolMongo:
+		return append([]string{"status"}, l7LatencyLabels(protocol)...)
+	}
+	return []string{"status"}
+}
+
+func l7LatencyLabels(protocol l7.Protocol) []string {
+	switch {
+	case protocol == l7.ProtocolHTTP || protocol == l7.ProtocolFastcgi:
+		var labels []string
+		if *flags.HTTPRouteLabels {
+			labels = append(labels, "method", "route")
+		}
+		if protocol == l7.ProtocolHTTP && *flags.CloudAPILabels {
+			labels = append(labels, "cloud_service", "cloud_operation")
+		}
+		if protocol == l7.ProtocolHTTP && *flags.GraphqlLabels {
+			labels = append(labels, "graphql_operation_type", "graphql_operation")
+		}
+		return labels
+	case protocol == l7.ProtocolRedis && *flags.RedisCommandLabels:
+		return []string{"command"}
+	case protocol == l7.ProtocolMongo && *flags.MongoCommandLabels:
+		return []string{"command", "db", "collection"}
 	}
+	return nil
 }
 
 type L7Stats map[l7.Protocol]map[common.DestinationKey]*L7Metrics // protocol -> dst:actual_dst -> metrics
@@ -50,33 +211,44 @@ func (s L7Stats) get(protocol l7.Protocol, key common.DestinationKey) *L7Metrics
 		m = &L7Metrics{}
 		protoStats[key] = m
 		constLabels := map[string]string{"destination": key.DestinationLabelValue(), "actual_destination": key.ActualDestinationLabelValue()}
-		labels := []string{"status"}
-		switch protocol {
-		case l7.ProtocolRabbitmq, l7.ProtocolNats:
-			labels = append(labels, "method")
-		default:
-			hOpts := L7Latency[protocol]
-			m.Latency = prometheus.NewHistogram(
-				prometheus.HistogramOpts{Name: hOpts.Name, Help: hOpts.Help, ConstLabels: constLabels},
+		if hOpts, ok := L7Latency[protocol]; ok {
+			m.Latency = prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{Name: hOpts.Name, Help: hOpts.Help, ConstLabels: constLabels}, l7LatencyLabels(protocol),
 			)
 		}
 		cOpts := L7Requests[protocol]
 		m.Requests = prometheus.NewCounterVec(
-			prometheus.CounterOpts{Name: cOpts.Name, Help: cOpts.Help, ConstLabels: constLabels}, labels,
+			prometheus.CounterOpts{Name: cOpts.Name, Help: cOpts.Help, ConstLabels: constLabels}, l7RequestLabels(protocol),
 		)
+		if protocol == l7.ProtocolPostgres {
+			m.Transactions = prometheus.NewCounterVec(
+				prometheus.CounterOpts{Name: PostgresTransactions.Name, Help: PostgresTransactions.Help, ConstLabels: constLabels}, []string{"status"},
+			)
+			m.IdleInTransaction = prometheus.NewCounter(
+				prometheus.CounterOpts{Name: PostgresIdleInTransaction.Name, Help: PostgresIdleInTransaction.Help, ConstLabels: constLabels},
+			)
+		}
+		m.statements = newStatementStats(protocol)
 	}
 	return m
 }
 
 func (s L7Stats) collect(ch chan<- prometheus.Metric) {
 	for _, protoStats := range s {
-		for _, m := range protoStats {
+		for key, m := range protoStats {
 			if m.Requests != nil {
 				m.Requests.Collect(ch)
 			}
 			if m.Latency != nil {
 				m.Latency.Collect(ch)
 			}
+			if m.Transactions != nil {
+				m.Transactions.Collect(ch)
+			}
+			if m.IdleInTransaction != nil {
+				m.IdleInTransaction.Collect(ch)
+			}
+			m.statements.collect(ch, key)
 		}
 	}
 }
@@ -90,3 +262,59 @@ func (s L7Stats) delete(dst common.HostPort) {
 		}
 	}
 }
+
+type InboundL7Stats map[l7.Protocol]map[netaddr.IPPort]*L7Metrics // protocol -> listen_addr -> metrics
+
+func (s InboundL7Stats) get(protocol l7.Protocol, listenAddr netaddr.IPPort) *L7Metrics {
+	if protocol == l7.ProtocolHTTP2 {
+		protocol = l7.ProtocolHTTP
+	}
+	protoStats := s[protocol]
+	if protoStats == nil {
+		protoStats = map[netaddr.IPPort]*L7Metrics{}
+		s[protocol] = protoStats
+	}
+	m := protoStats[listenAddr]
+	if m == nil {
+		m = &L7Metrics{}
+		protoStats[listenAddr] = m
+		constLabels := map[string]string{"listen_addr": listenAddr.String()}
+		if hOpts, ok := L7InboundLatency[protocol]; ok {
+			m.Latency = prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{Name: hOpts.Name, Help: hOpts.Help, ConstLabels: constLabels}, l7LatencyLabels(protocol),
+			)
+		}
+		if cOpts, ok := L7InboundRequests[protocol]; ok {
+			m.Requests = prometheus.NewCounterVec(
+				prometheus.CounterOpts{Name: cOpts.Name, Help: cOpts.Help, ConstLabels: constLabels}, l7RequestLabels(protocol),
+			)
+		}
+	}
+	return m
+}
+
+func (s InboundL7Stats) collect(ch chan<- prometheus.Metric) {
+	for _, protoStats := range s {
+		for _, m := range protoStats {
+			if m.Requests != nil {
+				m.Requests.Collect(ch)
+			}
+			if m.Latency != nil {
+				m.Latency.Collect(ch)
+			}
+		}
+	}
+}
+
+func (s InboundL7Stats) delete(listenAddr netaddr.IPPort) {
+	for _, protoStats := range s {
+		for addr := range protoStats {
+			if addr.Port() != listenAddr.Port() {
+				continue
+			}
+			if listenAddr.IP().IsUnspecified() || addr.IP() == listenAddr.IP() {
+				delete(protoStats, addr)
+			}
+		}
+	}
+}
@@ -40,6 +40,10 @@ var metrics = struct {
 
 	LogMessages *prometheus.Desc
 
+	DbStatementCalls  *prometheus.Desc
+	DbStatementErrors *prometheus.Desc
+	DbStatementTime   *prometheus.Desc
+
 	ApplicationType *prometheus.Desc
 
 	JvmInfo              *prometheus.Desc
@@ -91,6 +95,10 @@ var metrics = struct {
 
 	LogMessages: metric("container_log_messages_total", "Number of messages grouped by the automatically extracted repeated pattern", "source", "level", "pattern_hash", "sample"),
 
+	DbStatementCalls:  metric("container_db_statement_calls_total", "Number of calls of the top outbound database statements by total execution time", "destination", "actual_destination", "system", "statement_hash", "statement"),
+	DbStatementErrors: metric("container_db_statement_errors_total", "Number of failed calls of the top outbound database statements by total execution time", "destination", "actual_destination", "system", "statement_hash", "statement"),
+	DbStatementTime:   metric("container_db_statement_duration_seconds_total", "Total execution time of the top outbound database statements", "destination", "actual_destination", "system", "statement_hash", "statement"),
+
 	ApplicationType: metric("container_application_type", "Type of the application running in the container (e.g. memcached, postgres, mysql)", "application_type"),
 
 	JvmInfo:              metric("container_jvm_info", "Meta information about the JVM", "jvm", "java_version", "max_heap_size", "initial_heap_size", "max_heap_percentage", "initial_heap_percentage", "gc_type"),
@@ -110,34 +118,83 @@ var metrics = struct {
 
 var (
 	L7Requests = map[l7.Protocol]prometheus.CounterOpts{
-		l7.ProtocolHTTP:       {Name: "container_http_requests_total", Help: "Total number of outbound HTTP requests"},
-		l7.ProtocolPostgres:   {Name: "container_postgres_queries_total", Help: "Total number of outbound Postgres queries"},
-		l7.ProtocolRedis:      {Name: "container_redis_queries_total", Help: "Total number of outbound Redis queries"},
-		l7.ProtocolMemcached:  {Name: "container_memcached_queries_total", Help: "Total number of outbound Memcached queries"},
-		l7.ProtocolMysql:      {Name: "container_mysql_queries_total", Help: "Total number of outbound Mysql queries"},
-		l7.ProtocolMongo:      {Name: "container_mongo_queries_total", Help: "Total number of outbound Mongo queries"},
-		l7.ProtocolKafka:      {Name: "container_kafka_requests_total", Help: "Total number of outbound Kafka requests"},
-		l7.ProtocolCassandra:  {Name: "container_cassandra_queries_total", Help: "Total number of outbound Cassandra requests"},
-		l7.ProtocolRabbitmq:   {Name: "container_rabbitmq_messages_total", Help: "Total number of Rabbitmq messages produced or consumed by the container"},
-		l7.ProtocolNats:       {Name: "container_nats_messages_total", Help: "Total number of NATS messages produced or consumed by the container"},
-		l7.ProtocolDubbo2:     {Name: "container_dubbo_requests_total", Help: "Total number of outbound DUBBO requests"},
-		l7.ProtocolDNS:        {Name: "container_dns_requests_total", Help: "Total number of outbound DNS requests"},
-		l7.ProtocolClickhouse: {Name: "container_clickhouse_queries_total", Help: "Total number of outbound ClickHouse queries"},
-		l7.ProtocolZookeeper:  {Name: "container_zookeeper_requests_total", Help: "Total number of outbound Zookeeper requests"},
+		l7.ProtocolHTTP:          {Name: "container_http_requests_total", Help: "Total number of outbound HTTP requests"},
+		l7.ProtocolPostgres:      {Name: "container_postgres_queries_total", Help: "Total number of outbound Postgres queries"},
+		l7.ProtocolRedis:         {Name: "container_redis_queries_total", Help: "Total number of outbound Redis queries"},
+		l7.ProtocolMemcached:     {Name: "container_memcached_queries_total", Help: "Total number of outbound Memcached queries"},
+		l7.ProtocolMysql:         {Name: "container_mysql_queries_total", Help: "Total number of outbound Mysql queries"},
+		l7.ProtocolMongo:         {Name: "container_mongo_queries_total", Help: "Total number of outbound Mongo queries"},
+		l7.ProtocolKafka:         {Name: "container_kafka_requests_total", Help: "Total number of outbound Kafka requests"},
+		l7.ProtocolCassandra:     {Name: "container_cassandra_queries_total", Help: "Total number of outbound Cassandra requests"},
+		l7.ProtocolRabbitmq:      {Name: "container_rabbitmq_messages_total", Help: "Total number of Rabbitmq messages produced or consumed by the container"},
+		l7.ProtocolNats:          {Name: "container_nats_messages_total", Help: "Total number of NATS messages produced or consumed by the container"},
+		l7.ProtocolDubbo2:        {Name: "container_dubbo_requests_total", Help: "Total number of outbound DUBBO requests"},
+		l7.ProtocolDNS:           {Name: "container_dns_requests_total", Help: "Total number of outbound DNS requests"},
+		l7.ProtocolClickhouse:    {Name: "container_clickhouse_queries_total", Help: "Total number of outbound ClickHouse queries"},
+		l7.ProtocolZookeeper:     {Name: "container_zookeeper_requests_total", Help: "Total number of outbound Zookeeper requests"},
+		l7.ProtocolGrpc:          {Name: "container_grpc_requests_total", Help: "Total number of outbound gRPC requests"},
+		l7.ProtocolMssql:         {Name: "container_mssql_queries_total", Help: "Total number of outbound MSSQL queries"},
+		l7.ProtocolMqtt:          {Name: "container_mqtt_messages_total", Help: "Total number of MQTT messages published or received by the container"},
+		l7.ProtocolPulsar:        {Name: "container_pulsar_messages_total", Help: "Total number of Pulsar messages produced, consumed or acknowledged by the container"},
+		l7.ProtocolFastcgi:       {Name: "container_fastcgi_requests_total", Help: "Total number of outbound FastCGI requests"},
+		l7.ProtocolElasticsearch: {Name: "container_elasticsearch_requests_total", Help: "Total number of outbound Elasticsearch requests"},
 	}
 	L7Latency = map[l7.Protocol]prometheus.HistogramOpts{
-		l7.ProtocolHTTP:       {Name: "container_http_requests_duration_seconds_total", Help: "Histogram of the response time for each outbound HTTP request"},
-		l7.ProtocolPostgres:   {Name: "container_postgres_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Postgres query"},
-		l7.ProtocolRedis:      {Name: "container_redis_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Redis query"},
-		l7.ProtocolMemcached:  {Name: "container_memcached_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Memcached query"},
-		l7.ProtocolMysql:      {Name: "container_mysql_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Mysql query"},
-		l7.ProtocolMongo:      {Name: "container_mongo_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Mongo query"},
-		l7.ProtocolKafka:      {Name: "container_kafka_requests_duration_seconds_total", Help: "Histogram of the execution time for each outbound Kafka request"},
-		l7.ProtocolCassandra:  {Name: "container_cassandra_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Cassandra request"},
-		l7.ProtocolDubbo2:     {Name: "container_dubbo_requests_duration_seconds_total", Help: "Histogram of the response time for each outbound DUBBO request"},
-		l7.ProtocolDNS:        {Name: "container_dns_requests_duration_seconds_total", Help: "Histogram of the response time for each outbound DNS request"},
-		l7.ProtocolClickhouse: {Name: "container_clickhouse_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound ClickHouse query"},
-		l7.ProtocolZookeeper:  {Name: "container_zookeeper_requests_duration_seconds_total", Help: "Histogram of the execution time for each outbound Zookeeper request"},
+		l7.ProtocolHTTP:          {Name: "container_http_requests_duration_seconds_total", Help: "Histogram of the response time for each outbound HTTP request"},
+		l7.ProtocolPostgres:      {Name: "container_postgres_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Postgres query"},
+		l7.ProtocolRedis:         {Name: "container_redis_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Redis query"},
+		l7.ProtocolMemcached:     {Name: "container_memcached_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Memcached query"},
+		l7.ProtocolMysql:         {Name: "container_mysql_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Mysql query"},
+		l7.ProtocolMongo:         {Name: "container_mongo_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Mongo query"},
+		l7.ProtocolKafka:         {Name: "container_kafka_requests_duration_seconds_total", Help: "Histogram of the execution time for each outbound Kafka request"},
+		l7.ProtocolCassandra:     {Name: "container_cassandra_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound Cassandra request"},
+		l7.ProtocolDubbo2:        {Name: "container_dubbo_requests_duration_seconds_total", Help: "Histogram of the response time for each outbound DUBBO request"},
+		l7.ProtocolDNS:           {Name: "container_dns_requests_duration_seconds_total", Help: "Histogram of the response time for each outbound DNS request"},
+		l7.ProtocolClickhouse:    {Name: "container_clickhouse_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound ClickHouse query"},
+		l7.ProtocolZookeeper:     {Name: "container_zookeeper_requests_duration_seconds_total", Help: "Histogram of the execution time for each outbound Zookeeper request"},
+		l7.ProtocolGrpc:          {Name: "container_grpc_requests_duration_seconds_total", Help: "Histogram of the response time for each outbound gRPC request"},
+		l7.ProtocolMssql:         {Name: "container_mssql_queries_duration_seconds_total", Help: "Histogram of the execution time for each outbound MSSQL query"},
+		l7.ProtocolPulsar:        {Name: "container_pulsar_send_duration_seconds_total", Help: "Histogram of the time between sending each batch of messages to Pulsar and receiving the receipt"},
+		l7.ProtocolFastcgi:       {Name: "container_fastcgi_requests_duration_seconds_total", Help: "Histogram of the response time for each outbound FastCGI request"},
+		l7.ProtocolElasticsearch: {Name: "container_elasticsearch_requests_duration_seconds_total", Help: "Histogram of the response time for each outbound Elasticsearch request"},
+	}
+	DnsSearchPathMisses       = prometheus.CounterOpts{Name: "container_dns_search_path_misses_total", Help: "Total number of NXDOMAIN responses to the queries of the names expanded with the search domains, by the name finally queried"}
+	PostgresTransactions      = prometheus.CounterOpts{Name: "container_postgres_transactions_total", Help: "Total number of outbound Postgres transactions by outcome"}
+	PostgresIdleInTransaction = prometheus.CounterOpts{Name: "container_postgres_idle_in_transaction_seconds_total", Help: "Time spent by the container idle in open Postgres transactions in seconds"}
+
+	L7InboundRequests = map[l7.Protocol]prometheus.CounterOpts{
+		l7.ProtocolHTTP:          {Name: "container_http_inbound_requests_total", Help: "Total number of inbound HTTP requests served by the container"},
+		l7.ProtocolPostgres:      {Name: "container_postgres_inbound_queries_total", Help: "Total number of inbound Postgres queries served by the container"},
+		l7.ProtocolRedis:         {Name: "container_redis_inbound_queries_total", Help: "Total number of inbound Redis queries served by the container"},
+		l7.ProtocolMemcached:     {Name: "container_memcached_inbound_queries_total", Help: "Total number of inbound Memcached queries served by the container"},
+		l7.ProtocolMysql:         {Name: "container_mysql_inbound_queries_total", Help: "Total number of inbound Mysql queries served by the container"},
+		l7.ProtocolMongo:         {Name: "container_mongo_inbound_queries_total", Help: "Total number of inbound Mongo queries served by the container"},
+		l7.ProtocolKafka:         {Name: "container_kafka_inbound_requests_total", Help: "Total number of inbound Kafka requests served by the container"},
+		l7.ProtocolCassandra:     {Name: "container_cassandra_inbound_queries_total", Help: "Total number of inbound Cassandra requests served by the container"},
+		l7.ProtocolDubbo2:        {Name: "container_dubbo_inbound_requests_total", Help: "Total number of inbound DUBBO requests served by the container"},
+		l7.ProtocolClickhouse:    {Name: "container_clickhouse_inbound_queries_total", Help: "Total number of inbound ClickHouse queries served by the container"},
+		l7.ProtocolZookeeper:     {Name: "container_zookeeper_inbound_requests_total", Help: "Total number of inbound Zookeeper requests served by the container"},
+		l7.ProtocolGrpc:          {Name: "container_grpc_inbound_requests_total", Help: "Total number of inbound gRPC requests served by the container"},
+		l7.ProtocolMssql:         {Name: "container_mssql_inbound_queries_total", Help: "Total number of inbound MSSQL queries served by the container"},
+		l7.ProtocolFastcgi:       {Name: "container_fastcgi_inbound_requests_total", Help: "Total number of inbound FastCGI requests served by the container"},
+		l7.ProtocolElasticsearch: {Name: "container_elasticsearch_inbound_requests_total", Help: "Total number of inbound Elasticsearch requests served by the container"},
+	}
+	L7InboundLatency = map[l7.Protocol]prometheus.HistogramOpts{
+		l7.ProtocolHTTP:          {Name: "container_http_inbound_requests_duration_seconds_total", Help: "Histogram of the response time for each inbound HTTP request"},
+		l7.ProtocolPostgres:      {Name: "container_postgres_inbound_queries_duration_seconds_total", Help: "Histogram of the execution time for each inbound Postgres query"},
+		l7.ProtocolRedis:         {Name: "container_redis_inbound_queries_duration_seconds_total", Help: "Histogram of the execution time for each inbound Redis query"},
+		l7.ProtocolMemcached:     {Name: "container_memcached_inbound_queries_duration_seconds_total", Help: "Histogram of the execution time for each inbound Memcached query"},
+		l7.ProtocolMysql:         {Name: "container_mysql_inbound_queries_duration_seconds_total", Help: "Histogram of the execution time for each inbound Mysql query"},
+		l7.ProtocolMongo:         {Name: "container_mongo_inbound_queries_duration_seconds_total", Help: "Histogram of the execution time for each inbound Mongo query"},
+		l7.ProtocolKafka:         {Name: "container_kafka_inbound_requests_duration_seconds_total", Help: "Histogram of the execution time for each inbound Kafka request"},
+		l7.ProtocolCassandra:     {Name: "container_cassandra_inbound_queries_duration_seconds_total", Help: "Histogram of the execution time for each inbound Cassandra request"},
+		l7.ProtocolDubbo2:        {Name: "container_dubbo_inbound_requests_duration_seconds_total", Help: "Histogram of the response time for each inbound DUBBO request"},
+		l7.ProtocolClickhouse:    {Name: "container_clickhouse_inbound_queries_duration_seconds_total", Help: "Histogram of the execution time for each inbound ClickHouse query"},
+		l7.ProtocolZookeeper:     {Name: "container_zookeeper_inbound_requests_duration_seconds_total", Help: "Histogram of the execution time for each inbound Zookeeper request"},
+		l7.ProtocolGrpc:          {Name: "container_grpc_inbound_requests_duration_seconds_total", Help: "Histogram of the response time for each inbound gRPC request"},
+		l7.ProtocolMssql:         {Name: "container_mssql_inbound_queries_duration_seconds_total", Help: "Histogram of the execution time for each inbound MSSQL query"},
+		l7.ProtocolFastcgi:       {Name: "container_fastcgi_inbound_requests_duration_seconds_total", Help: "Histogram of the response time for each inbound FastCGI request"},
+		l7.ProtocolElasticsearch: {Name: "container_elasticsearch_inbound_requests_duration_seconds_total", Help: "Histogram of the response time for each inbound Elasticsearch request"},
 	}
 )
 
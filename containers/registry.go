@@ -268,6 +268,11 @@ func (r *Registry) handleEvents(ch <-chan ebpftracer.Event) {
 				} else {
 					klog.Infoln("TCP connection from unknown container", e)
 				}
+			case ebpftracer.EventTypeConnectionAccept:
+				if c := r.getOrCreateContainer(e.Pid); c != nil {
+					c.onConnectionAccept(e.Pid, e.Fd, e.SrcAddr, e.DstAddr, e.Timestamp)
+					c.attachTlsUprobes(r.tracer, e.Pid)
+				}
 			case ebpftracer.EventTypeConnectionError:
 				if c := r.getOrCreateContainer(e.Pid); c != nil {
 					c.onConnectionOpen(e.Pid, e.Fd, e.SrcAddr, e.DstAddr, e.ActualDstAddr, 0, true, e.Duration)
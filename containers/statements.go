@@ -0,0 +1,154 @@
+package containers
+
+import (
+	"crypto/md5"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coroot/coroot-node-agent/common"
+	"github.com/coroot/coroot-node-agent/ebpftracer/l7"
+	"github.com/coroot/coroot-node-agent/flags"
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The number of tracked statements is limited to keep the memory usage bounded: once the limit is reached,
+// the statement with the lowest total time among the ones tracked for at least statementStatsMinAge is evicted.
+// If there is no such statement, the new one is not tracked, so new statements can't evict each other.
+// The stats of the evicted statements are kept for a while to let the counters continue from the same values
+// if the statements are tracked again.
+const (
+	statementStatsTrackedPerTopN = 10
+	statementStatsMinAge         = 10 * time.Minute
+)
+
+type statementStat struct {
+	statement string
+	calls     uint64
+	errors    uint64
+	totalTime time.Duration
+	trackedAt time.Time
+}
+
+type statementStats struct {
+	system     string
+	statements map[string]*statementStat // fingerprint -> stat
+	evicted    *simplelru.LRU[string, *statementStat]
+}
+
+func newStatementStats(protocol l7.Protocol) *statementStats {
+	if *flags.DbStatementsTopN <= 0 {
+		return nil
+	}
+	var system string
+	switch protocol {
+	case l7.ProtocolPostgres:
+		system = "postgresql"
+	case l7.ProtocolMysql:
+		system = "mysql"
+	case l7.ProtocolClickhouse:
+		system = "clickhouse"
+	case l7.ProtocolMssql:
+		system = "mssql"
+	default:
+		return nil
+	}
+	evicted, _ := simplelru.NewLRU[string, *statementStat](*flags.DbStatementsTopN*statementStatsTrackedPerTopN, nil)
+	return &statementStats{system: system, statements: map[string]*statementStat{}, evicted: evicted}
+}
+
+func (s *statementStats) observe(query string, error bool, duration time.Duration) {
+	s.observeAt(query, error, duration, time.Now())
+}
+
+func (s *statementStats) observeAt(query string, error bool, duration time.Duration, now time.Time) {
+	if s == nil || query == "" {
+		return
+	}
+	statement := normalizeStatement(query, s.system == "mysql")
+	fingerprint := statementFingerprint(statement)
+	st := s.statements[fingerprint]
+	if st == nil {
+		if len(s.statements) >= *flags.DbStatementsTopN*statementStatsTrackedPerTopN && !s.evict(now) {
+			return
+		}
+		if st, _ = s.evicted.Get(fingerprint); st != nil {
+			s.evicted.Remove(fingerprint)
+		} else {
+			st = &statementStat{statement: statement}
+		}
+		st.trackedAt = now
+		s.statements[fingerprint] = st
+	}
+	st.calls++
+	if error {
+		st.errors++
+	}
+	st.totalTime += duration
+}
+
+// evict removes the statement with the lowest total time among the ones tracked for at least statementStatsMinAge.
+// It returns false if there is no such statement.
+func (s *statementStats) evict(now time.Time) bool {
+	var fingerprint string
+	var least *statementStat
+	for f, st := range s.statements {
+		if now.Sub(st.trackedAt) < statementStatsMinAge {
+			continue
+		}
+		if least == nil || st.totalTime < least.totalTime {
+			fingerprint, least = f, st
+		}
+	}
+	if least == nil {
+		return false
+	}
+	delete(s.statements, fingerprint)
+	s.evicted.Add(fingerprint, least)
+	return true
+}
+
+func (s *statementStats) collect(ch chan<- prometheus.Metric, key common.DestinationKey) {
+	if s == nil {
+		return
+	}
+	fingerprints := make([]string, 0, len(s.statements))
+	for f := range s.statements {
+		fingerprints = append(fingerprints, f)
+	}
+	sort.Slice(fingerprints, func(i, j int) bool {
+		return s.statements[fingerprints[i]].totalTime > s.statements[fingerprints[j]].totalTime
+	})
+	if len(fingerprints) > *flags.DbStatementsTopN {
+		fingerprints = fingerprints[:*flags.DbStatementsTopN]
+	}
+	dst, actualDst := key.DestinationLabelValue(), key.ActualDestinationLabelValue()
+	for _, f := range fingerprints {
+		st := s.statements[f]
+		statement := common.TruncateUtf8(st.statement, *flags.MaxLabelLength)
+		ch <- counter(metrics.DbStatementCalls, float64(st.calls), dst, actualDst, s.system, f, statement)
+		ch <- counter(metrics.DbStatementErrors, float64(st.errors), dst, actualDst, s.system, f, statement)
+		ch <- counter(metrics.DbStatementTime, st.totalTime.Seconds(), dst, actualDst, s.system, f, statement)
+	}
+}
+
+func statementFingerprint(statement string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(statement)))
+}
+
+// normalizeStatement replaces literals with placeholders and collapses whitespaces,
+// so executions of the same statement with different parameters get the same fingerprint.
+func normalizeStatement(query string, mysql bool) string {
+	if strings.HasPrefix(query, "PREPARE ") { // PREPARE <name> AS <query> (Postgres) or PREPARE <id> FROM <query> (MySQL)
+		sep := " AS "
+		if mysql {
+			sep = " FROM "
+		}
+		if _, q, ok := strings.Cut(query, sep); ok {
+			query = q
+		}
+	}
+	return strings.Join(strings.Fields(common.ObfuscateSQL(query, mysql)), " ")
+}
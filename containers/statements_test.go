@@ -0,0 +1,148 @@
+package containers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coroot/coroot-node-agent/common"
+	"github.com/coroot/coroot-node-agent/ebpftracer/l7"
+	"github.com/coroot/coroot-node-agent/flags"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"inet.af/netaddr"
+)
+
+func TestNormalizeStatement(t *testing.T) {
+	for _, c := range []struct {
+		query, expected string
+		mysql           bool
+	}{
+		{query: "SELECT * FROM users WHERE id = 1", expected: "SELECT * FROM users WHERE id = ?"},
+		{query: "SELECT *\n  FROM users\tWHERE name = 'bob'", expected: "SELECT * FROM users WHERE name = ?"},
+		{query: "SELECT * FROM users WHERE id IN (1, 2, 3)", expected: "SELECT * FROM users WHERE id IN (?)"},
+		{query: "PREPARE s1 AS SELECT * FROM users WHERE id = $1", expected: "SELECT * FROM users WHERE id = $1"},
+		{query: `SELECT * FROM users WHERE name = "bob"`, expected: "SELECT * FROM users WHERE name = ?", mysql: true},
+		{query: "PREPARE s1 FROM SELECT * FROM users WHERE id = ?", expected: "SELECT * FROM users WHERE id = ?", mysql: true},
+	} {
+		assert.Equal(t, c.expected, normalizeStatement(c.query, c.mysql), c.query)
+	}
+
+	assert.Equal(t,
+		statementFingerprint(normalizeStatement("SELECT * FROM users WHERE id = 1", false)),
+		statementFingerprint(normalizeStatement("SELECT * FROM users  WHERE id = 2", false)),
+	)
+	assert.NotEqual(t,
+		statementFingerprint(normalizeStatement("SELECT * FROM users WHERE id = 1", false)),
+		statementFingerprint(normalizeStatement("SELECT * FROM orders WHERE id = 1", false)),
+	)
+}
+
+func TestStatementStats(t *testing.T) {
+	topN, maxLabelLength := *flags.DbStatementsTopN, *flags.MaxLabelLength
+	defer func() { *flags.DbStatementsTopN, *flags.MaxLabelLength = topN, maxLabelLength }()
+	*flags.MaxLabelLength = 4096
+
+	*flags.DbStatementsTopN = 0
+	assert.Nil(t, newStatementStats(l7.ProtocolPostgres))
+
+	*flags.DbStatementsTopN = 1
+	assert.Nil(t, newStatementStats(l7.ProtocolRedis))
+	s := newStatementStats(l7.ProtocolPostgres)
+	require.NotNil(t, s)
+
+	now := time.Now()
+	s.observeAt("SELECT * FROM users WHERE id = 1", false, 100*time.Millisecond, now)
+	s.observeAt("SELECT * FROM users WHERE id = 2", true, 200*time.Millisecond, now)
+	s.observeAt("SELECT * FROM orders WHERE id = 1", false, time.Second, now)
+
+	stats := collectStatementStats(s)
+	assert.Len(t, stats, 1) // top 1
+	assert.Equal(t, statementMetricValues{calls: 1, errors: 0, time: 1}, stats["SELECT * FROM orders WHERE id = ?"])
+
+	*flags.DbStatementsTopN = 2
+	stats = collectStatementStats(s)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, statementMetricValues{calls: 2, errors: 1, time: 0.3}, stats["SELECT * FROM users WHERE id = ?"])
+}
+
+func TestStatementStatsEviction(t *testing.T) {
+	topN := *flags.DbStatementsTopN
+	defer func() { *flags.DbStatementsTopN = topN }()
+	*flags.DbStatementsTopN = 1
+	limit := statementStatsTrackedPerTopN
+
+	s := newStatementStats(l7.ProtocolPostgres)
+	now := time.Now()
+	query := func(i int) string {
+		return fmt.Sprintf("SELECT * FROM t%d", i)
+	}
+	for i := 0; i < limit; i++ {
+		s.observeAt(query(i), false, time.Duration(i+1)*time.Second, now)
+	}
+	assert.Len(t, s.statements, limit)
+
+	// the tracked statements are too young to be evicted, so the new ones are not tracked
+	s.observeAt(query(100), false, time.Hour, now.Add(time.Minute))
+	s.observeAt(query(101), false, time.Hour, now.Add(time.Minute))
+	assert.Len(t, s.statements, limit)
+	assert.Nil(t, s.statements[statementFingerprint(query(100))])
+
+	// the statement with the lowest total time is evicted
+	later := now.Add(statementStatsMinAge)
+	s.observeAt(query(100), false, time.Hour, later)
+	assert.Len(t, s.statements, limit)
+	assert.NotNil(t, s.statements[statementFingerprint(query(100))])
+	assert.Nil(t, s.statements[statementFingerprint(query(0))])
+
+	// the new statement is protected by the minimum age, while the others can be evicted
+	s.observeAt(query(101), false, time.Minute, later)
+	assert.NotNil(t, s.statements[statementFingerprint(query(100))])
+	assert.NotNil(t, s.statements[statementFingerprint(query(101))])
+	assert.Nil(t, s.statements[statementFingerprint(query(1))])
+
+	// an evicted statement continues from its previous values when tracked again
+	s.observeAt(query(0), false, time.Second, later)
+	st := s.statements[statementFingerprint(query(0))]
+	require.NotNil(t, st)
+	assert.Equal(t, uint64(2), st.calls)
+	assert.Equal(t, 2*time.Second, st.totalTime)
+	assert.Nil(t, s.statements[statementFingerprint(query(2))])
+}
+
+type statementMetricValues struct {
+	calls, errors, time float64
+}
+
+func collectStatementStats(s *statementStats) map[string]statementMetricValues {
+	key := common.NewDestinationKey(netaddr.MustParseIPPort("10.0.0.1:5432"), netaddr.MustParseIPPort("10.0.0.1:5432"), nil)
+	ch := make(chan prometheus.Metric)
+	go func() {
+		s.collect(ch, key)
+		close(ch)
+	}()
+	res := map[string]statementMetricValues{}
+	for m := range ch {
+		pb := &dto.Metric{}
+		_ = m.Write(pb)
+		var statement string
+		for _, l := range pb.Label {
+			if l.GetName() == "statement" {
+				statement = l.GetValue()
+			}
+		}
+		v := res[statement]
+		switch m.Desc() {
+		case metrics.DbStatementCalls:
+			v.calls = pb.Counter.GetValue()
+		case metrics.DbStatementErrors:
+			v.errors = pb.Counter.GetValue()
+		case metrics.DbStatementTime:
+			v.time = pb.Counter.GetValue()
+		}
+		res[statement] = v
+	}
+	return res
+}
@@ -15,6 +15,7 @@
 #define EVENT_TYPE_FILE_OPEN		    8
 #define EVENT_TYPE_TCP_RETRANSMIT	    9
 #define EVENT_TYPE_PYTHON_THREAD_LOCK	11
+#define EVENT_TYPE_CONNECTION_ACCEPT	12
 
 #define EVENT_REASON_OOM_KILL		1
 
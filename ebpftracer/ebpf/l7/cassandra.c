@@ -6,9 +6,12 @@
 #define CASSANDRA_OPCODE_ERROR      0x00
 #define CASSANDRA_OPCODE_QUERY      0x07
 #define CASSANDRA_OPCODE_RESULT     0x08
+#define CASSANDRA_OPCODE_PREPARE    0x09
 #define CASSANDRA_OPCODE_EXECUTE    0x0A
 #define CASSANDRA_OPCODE_BATCH      0x0D
 
+#define CASSANDRA_RESULT_KIND_PREPARED 0x0004
+
 struct cassandra_header {
     __u8 version;
     __u8 flags;
@@ -26,7 +29,7 @@ int is_cassandra_request(char *buf, __u64 buf_size, __s16 *stream_id) {
     if (h.version != CASSANDRA_REQUEST_FRAME) {
         return 0;
     }
-    if (h.opcode == CASSANDRA_OPCODE_QUERY || h.opcode == CASSANDRA_OPCODE_EXECUTE || h.opcode == CASSANDRA_OPCODE_BATCH) {
+    if (h.opcode == CASSANDRA_OPCODE_QUERY || h.opcode == CASSANDRA_OPCODE_PREPARE || h.opcode == CASSANDRA_OPCODE_EXECUTE || h.opcode == CASSANDRA_OPCODE_BATCH) {
         *stream_id = h.stream_id;
         return 1;
     }
@@ -34,7 +37,7 @@ int is_cassandra_request(char *buf, __u64 buf_size, __s16 *stream_id) {
 }
 
 static __always_inline
-int is_cassandra_response(char *buf, __u64 buf_size, __s16 *stream_id, __s32 *status) {
+int is_cassandra_response(char *buf, __u64 buf_size, __s16 *stream_id, __s32 *status, __u32 *statement_id) {
     struct cassandra_header h = {};
     if (buf_size < sizeof(h)) {
         return 0;
@@ -46,6 +49,14 @@ int is_cassandra_response(char *buf, __u64 buf_size, __s16 *stream_id, __s32 *st
     if (h.opcode == CASSANDRA_OPCODE_RESULT) {
         *stream_id = h.stream_id;
         *status = STATUS_OK;
+        // header(9) + kind(4) + prepared_id length(2) + the first 4 bytes of the prepared id
+        if (buf_size >= 19) {
+            __s32 kind = 0;
+            bpf_read(buf+9, kind);
+            if (bpf_htonl(kind) == CASSANDRA_RESULT_KIND_PREPARED) {
+                bpf_read(buf+15, *statement_id);
+            }
+        }
         return 1;
     }
     if (h.opcode == CASSANDRA_OPCODE_ERROR) {
@@ -10,13 +10,37 @@ struct dns_header {
     __s16 qdcount;
 };
 
+// Over TCP, a message is prefixed with its length. The response can also be read
+// with separate reads of the length and of the message.
+static __always_inline
+int dns_read_header(char *buf, __u64 buf_size, struct dns_header *h) {
+    if (buf_size < sizeof(*h)) {
+        return 0;
+    }
+    if (buf_size >= sizeof(*h) + 2) {
+        __u16 length;
+        bpf_read(buf, length);
+        if (bpf_ntohs(length) == buf_size - 2) {
+            if (bpf_probe_read(h, sizeof(*h), buf + 2) < 0) {
+                return 0;
+            }
+            if (bpf_ntohs(h->qdcount) == 1 && !(h->bits0 & DNS_OPCODE)) {
+                return 1;
+            }
+        }
+    }
+    if (bpf_probe_read(h, sizeof(*h), buf) < 0) {
+        return 0;
+    }
+    return 1;
+}
+
 static __always_inline
 int is_dns_request(char *buf, __u64 buf_size, __s16 *stream_id) {
     struct dns_header h = {};
-    if (buf_size < sizeof(h)) {
+    if (!dns_read_header(buf, buf_size, &h)) {
         return 0;
     }
-    bpf_read(buf, h);
     if (h.bits0 & DNS_QR_RESPONSE) {
         return 0;
     }
@@ -35,10 +59,9 @@ int is_dns_request(char *buf, __u64 buf_size, __s16 *stream_id) {
 static __always_inline
 int is_dns_response(char *buf, __u64 buf_size, __s16 *stream_id, __s32 *status) {
     struct dns_header h = {};
-    if (buf_size < sizeof(h)) {
+    if (!dns_read_header(buf, buf_size, &h)) {
         return 0;
     }
-    bpf_read(buf, h);
     if (!(h.bits0 & DNS_QR_RESPONSE)) {
         return 0;
     }
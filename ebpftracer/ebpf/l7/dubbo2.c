@@ -40,7 +40,7 @@ int is_dubbo2_request(char *buf, __u64 buf_size) {
 
 
 static __always_inline
-int is_dubbo2_response(char *buf, __s32 *status) {
+int is_dubbo2_response(char *buf, __s32 *status, __u32 *code) {
     __u8 b[16];
     bpf_read(buf, b);
     if (b[0] != DUBBO_MAGIC_HIGH || b[1] != DUBBO_MAGIC_LOW) {
@@ -51,6 +51,8 @@ int is_dubbo2_response(char *buf, __s32 *status) {
         return 0;
     }
 
+    // the original Dubbo status code is passed to the user space in the statement_id field
+    *code = b[3];
     if (b[3] == DUBBO_RESPONSE_OK) {
         *status = STATUS_OK;
         return 1;
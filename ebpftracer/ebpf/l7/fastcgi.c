@@ -0,0 +1,57 @@
+// https://fastcgi-archives.github.io/FastCGI_Specification.html
+
+#define FASTCGI_VERSION_1       1
+#define FASTCGI_BEGIN_REQUEST   1
+#define FASTCGI_END_REQUEST     3
+#define FASTCGI_STDOUT          6
+#define FASTCGI_STDERR          7
+#define FASTCGI_RESPONDER       1
+
+struct fastcgi_header {
+    __u8 version;
+    __u8 type;
+    __u16 request_id;
+    __u16 content_length;
+    __u8 padding_length;
+    __u8 reserved;
+};
+
+// A request starts with the BEGIN_REQUEST record followed by PARAMS and STDIN records.
+static __always_inline
+int is_fastcgi_request(char *buf, __u64 buf_size) {
+    struct fastcgi_header h = {};
+    if (buf_size < sizeof(h) + 8) {
+        return 0;
+    }
+    bpf_read(buf, h);
+    if (h.version != FASTCGI_VERSION_1 || h.type != FASTCGI_BEGIN_REQUEST || bpf_ntohs(h.content_length) != 8) {
+        return 0;
+    }
+    __u16 role;
+    bpf_read(buf+sizeof(h), role);
+    return bpf_ntohs(role) == FASTCGI_RESPONDER;
+}
+
+// The response status is parsed in user space from the headers written to STDOUT.
+static __always_inline
+int is_fastcgi_response(char *buf, __u64 buf_size, __s32 *status) {
+    struct fastcgi_header h = {};
+    if (buf_size < sizeof(h)) {
+        return 0;
+    }
+    bpf_read(buf, h);
+    if (h.version != FASTCGI_VERSION_1) {
+        return 0;
+    }
+    switch (h.type) {
+    case FASTCGI_STDOUT:
+        *status = STATUS_OK;
+        return 1;
+    case FASTCGI_END_REQUEST: // no output
+        *status = STATUS_FAILED;
+        return 1;
+    case FASTCGI_STDERR: // e.g., PHP warnings preceding the output
+        return 2;
+    }
+    return 0;
+}
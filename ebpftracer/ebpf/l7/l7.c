@@ -14,6 +14,12 @@
 #define PROTOCOL_DNS        13
 #define PROTOCOL_CLICKHOUSE 14
 #define PROTOCOL_ZOOKEEPER  15
+// 16 is reserved for gRPC, which is detected in user space
+#define PROTOCOL_MSSQL      17
+#define PROTOCOL_MQTT       18
+#define PROTOCOL_PULSAR     19
+#define PROTOCOL_FASTCGI    20
+// 21 is reserved for Elasticsearch, which is detected in user space
 
 #define STATUS_UNKNOWN  0
 #define STATUS_OK       200
@@ -26,6 +32,12 @@
 #define METHOD_STATEMENT_CLOSE      4
 #define METHOD_HTTP2_CLIENT_FRAMES  5
 #define METHOD_HTTP2_SERVER_FRAMES  6
+#define METHOD_ERROR_RESPONSE       7
+#define METHOD_RESPONSE             8
+#define METHOD_MQTT_CLIENT_PACKETS  9
+#define METHOD_MQTT_SERVER_PACKETS  10
+#define METHOD_PULSAR_CLIENT_FRAMES 11
+#define METHOD_PULSAR_SERVER_FRAMES 12
 
 #define TRUNCATE_PAYLOAD_SIZE(size) ({                                  \
     size = MIN(size, MAX_PAYLOAD_SIZE-1);                               \
@@ -56,6 +68,10 @@
 #include "dns.c"
 #include "clickhouse.c"
 #include "zookeeper.c"
+#include "mssql.c"
+#include "mqtt.c"
+#include "pulsar.c"
+#include "fastcgi.c"
 
 struct l7_event {
     __u64 fd;
@@ -65,9 +81,11 @@ struct l7_event {
     __u64 duration;
     __u8 protocol;
     __u8 method;
-    __u16 padding;
+    __u8 inbound;
+    __u8 padding;
     __u32 statement_id;
     __u64 payload_size;
+    __u64 timestamp;
     char payload[MAX_PAYLOAD_SIZE];
 };
 
@@ -136,10 +154,12 @@ struct user_msghdr {
 };
 
 static inline __attribute__((__always_inline__))
-void send_event(void *ctx, struct l7_event *e, struct connection_id cid, struct connection *conn) {
+void send_event(void *ctx, struct l7_event *e, struct connection_id cid, struct connection *conn, __u8 inbound) {
     e->connection_timestamp = conn->timestamp;
+    e->inbound = inbound;
     e->fd = cid.fd;
     e->pid = cid.pid;
+    e->timestamp = bpf_ktime_get_ns();
     bpf_perf_event_output(ctx, &l7_events, BPF_F_CURRENT_CPU, e, sizeof(*e));
 }
 
@@ -174,6 +194,231 @@ __u64 read_iovec(char *iovec, __u64 iovlen, __u64 ret, char *buf, __u64 *total_s
     return offset;
 }
 
+#define L7_RESPONSE_KEEP_REQUEST -1
+
+// Returns the method of the event carrying the response payload, or METHOD_UNKNOWN if the payload is not needed.
+static inline __attribute__((__always_inline__))
+__u8 response_payload_method(struct l7_event *e) {
+    if ((e->protocol == PROTOCOL_POSTGRES || e->protocol == PROTOCOL_REDIS || e->protocol == PROTOCOL_MSSQL || e->protocol == PROTOCOL_MEMCACHED || e->protocol == PROTOCOL_MONGO) && e->status == STATUS_FAILED) {
+        return METHOD_ERROR_RESPONSE;
+    }
+    if (e->protocol == PROTOCOL_FASTCGI) {
+        return METHOD_RESPONSE;
+    }
+    return METHOD_UNKNOWN;
+}
+
+// Sends the response payload followed by the request payload to let the user space parse response details (e.g., errors).
+// Both events are sent to the same CPU buffer, so the response is always received first.
+static inline __attribute__((__always_inline__))
+int send_response_payload(void *ctx, struct l7_event *e, struct l7_request *req, char *payload, __u64 size, __u8 response_method, struct connection_id cid, struct connection *conn, __u8 inbound) {
+    __u8 method = e->method;
+    e->method = response_method;
+    e->duration = 0;
+    e->payload_size = size;
+    COPY_PAYLOAD(e->payload, size, payload);
+    send_event(ctx, e, cid, conn, inbound);
+    e->method = method;
+    e->payload_size = req->payload_size;
+    COPY_PAYLOAD(e->payload, req->payload_size, req->payload);
+    return 1;
+}
+
+static inline __attribute__((__always_inline__))
+int is_l7_response(struct l7_event *e, struct l7_request *req, char *payload, __u64 size, __u64 total_size) {
+    int response = 0;
+    if (e->protocol == PROTOCOL_HTTP) {
+        response = is_http_response(payload, &e->status);
+    } else if (e->protocol == PROTOCOL_POSTGRES) {
+        response = is_postgres_response(payload, size, &e->status);
+        if (req->request_type == POSTGRES_FRAME_PARSE) {
+            e->method = METHOD_STATEMENT_PREPARE;
+        }
+    } else if (e->protocol == PROTOCOL_REDIS) {
+        response = is_redis_response(payload, size, &e->status);
+    } else if (e->protocol == PROTOCOL_MEMCACHED) {
+        response = is_memcached_response(payload, size, &e->status);
+    } else if (e->protocol == PROTOCOL_MYSQL) {
+        response = is_mysql_response(payload, size, req->request_type, &e->statement_id, &e->status);
+        if (req->request_type == MYSQL_COM_STMT_PREPARE) {
+            e->method = METHOD_STATEMENT_PREPARE;
+        }
+    } else if (e->protocol == PROTOCOL_MONGO) {
+        response = is_mongo_response(payload, size, req->partial, &e->status);
+        if (response == 2) { // partial
+            req->partial = 1;
+            return L7_RESPONSE_KEEP_REQUEST;
+        }
+    } else if (e->protocol == PROTOCOL_KAFKA) {
+        response = is_kafka_response(payload, req->request_id);
+    } else if (e->protocol == PROTOCOL_CLICKHOUSE) {
+        response = is_clickhouse_response(payload, &e->status);
+        if (!response) {
+            return L7_RESPONSE_KEEP_REQUEST;
+        }
+    } else if (e->protocol == PROTOCOL_ZOOKEEPER) {
+        response = is_zk_response(payload, total_size, &e->status, req->partial);
+        if (response == 2) { // partial
+            req->partial = 1;
+            return L7_RESPONSE_KEEP_REQUEST;
+        }
+    } else if (e->protocol == PROTOCOL_DUBBO2) {
+        response = is_dubbo2_response(payload, &e->status, &e->statement_id);
+    } else if (e->protocol == PROTOCOL_MSSQL) {
+        response = is_mssql_response(payload, size, &e->status);
+    } else if (e->protocol == PROTOCOL_FASTCGI) {
+        response = is_fastcgi_response(payload, size, &e->status);
+        if (response == 2) { // waiting for STDOUT
+            return L7_RESPONSE_KEEP_REQUEST;
+        }
+    }
+    return response;
+}
+
+// Server side: a request is read from an accepted connection.
+static inline __attribute__((__always_inline__))
+int trace_inbound_request(void *ctx, struct connection_id cid, struct connection *conn, __u16 is_tls, char *payload, __u64 size, __u64 total_size) {
+    int zero = 0;
+    struct l7_request *req = bpf_map_lookup_elem(&l7_request_heap, &zero);
+    if (!req) {
+        return 0;
+    }
+    req->protocol = PROTOCOL_UNKNOWN;
+    req->partial = 0;
+    req->request_id = 0;
+    req->ns = 0;
+    req->payload_size = size;
+    struct l7_request_key k = {};
+    k.pid = cid.pid;
+    k.fd = cid.fd;
+    k.is_tls = is_tls;
+    k.stream_id = -1;
+
+    if (is_http_request(payload)) {
+        req->protocol = PROTOCOL_HTTP;
+    } else if (is_postgres_query(payload, size, &req->request_type)) {
+        if (req->request_type == POSTGRES_FRAME_CLOSE) {
+            return 0;
+        }
+        req->protocol = PROTOCOL_POSTGRES;
+    } else if (is_redis_query(payload, size)) {
+        req->protocol = PROTOCOL_REDIS;
+    } else if (is_memcached_query(payload, size)) {
+        req->protocol = PROTOCOL_MEMCACHED;
+    } else if (is_mysql_query(payload, size, &req->request_type)) {
+        if (req->request_type == MYSQL_COM_STMT_CLOSE) {
+            return 0;
+        }
+        req->protocol = PROTOCOL_MYSQL;
+    } else if (is_mongo_query(payload, size)) {
+        req->protocol = PROTOCOL_MONGO;
+    } else if (is_cassandra_request(payload, size, &k.stream_id)) {
+        req->protocol = PROTOCOL_CASSANDRA;
+    } else if (looks_like_http2_frame(payload, size, METHOD_HTTP2_CLIENT_FRAMES)) {
+        struct l7_event *e = bpf_map_lookup_elem(&l7_event_heap, &zero);
+        if (!e) {
+            return 0;
+        }
+        e->protocol = PROTOCOL_HTTP2;
+        e->method = METHOD_HTTP2_CLIENT_FRAMES;
+        e->status = STATUS_UNKNOWN;
+        e->statement_id = 0;
+        e->duration = bpf_ktime_get_ns();
+        e->payload_size = size;
+        COPY_PAYLOAD(e->payload, size, payload);
+        send_event(ctx, e, cid, conn, 1);
+        return 0;
+    } else if (is_clickhouse_query(payload, size)) {
+        req->protocol = PROTOCOL_CLICKHOUSE;
+    } else if (is_zk_request(payload, total_size)) {
+        req->protocol = PROTOCOL_ZOOKEEPER;
+    } else if (is_kafka_request(payload, size, &req->request_id)) {
+        req->protocol = PROTOCOL_KAFKA;
+    } else if (is_dubbo2_request(payload, size)) {
+        req->protocol = PROTOCOL_DUBBO2;
+    } else if (is_mssql_query(payload, size)) {
+        req->protocol = PROTOCOL_MSSQL;
+    } else if (is_fastcgi_request(payload, size)) {
+        req->protocol = PROTOCOL_FASTCGI;
+    }
+
+    if (req->protocol == PROTOCOL_UNKNOWN) {
+        return 0;
+    }
+    req->ns = bpf_ktime_get_ns();
+    COPY_PAYLOAD(req->payload, size, payload);
+    bpf_map_update_elem(&active_l7_requests, &k, req, BPF_NOEXIST);
+    return 0;
+}
+
+// Server side: a response is written to an accepted connection.
+static inline __attribute__((__always_inline__))
+int trace_inbound_response(void *ctx, struct connection_id cid, struct connection *conn, __u16 is_tls, char *payload, __u64 size, __u64 total_size) {
+    int zero = 0;
+    struct l7_event *e = bpf_map_lookup_elem(&l7_event_heap, &zero);
+    if (!e) {
+        return 0;
+    }
+    e->protocol = PROTOCOL_UNKNOWN;
+    e->status = STATUS_UNKNOWN;
+    e->method = METHOD_UNKNOWN;
+    e->statement_id = 0;
+    e->payload_size = 0;
+
+    struct l7_request_key k = {};
+    k.pid = cid.pid;
+    k.fd = cid.fd;
+    k.is_tls = is_tls;
+    k.stream_id = -1;
+
+    struct l7_request *req = bpf_map_lookup_elem(&active_l7_requests, &k);
+    int response = 0;
+    if (!req) {
+        if (is_cassandra_response(payload, size, &k.stream_id, &e->status, &e->statement_id)) {
+            req = bpf_map_lookup_elem(&active_l7_requests, &k);
+            if (!req) {
+                return 0;
+            }
+            if (e->statement_id) {
+                e->method = METHOD_STATEMENT_PREPARE;
+            }
+            response = 1;
+        } else if (looks_like_http2_frame(payload, size, METHOD_HTTP2_SERVER_FRAMES)) {
+            e->protocol = PROTOCOL_HTTP2;
+            e->method = METHOD_HTTP2_SERVER_FRAMES;
+            e->duration = bpf_ktime_get_ns();
+            e->payload_size = size;
+            COPY_PAYLOAD(e->payload, size, payload);
+            send_event(ctx, e, cid, conn, 1);
+            return 0;
+        } else {
+            return 0;
+        }
+    }
+
+    e->protocol = req->protocol;
+    e->payload_size = req->payload_size;
+    COPY_PAYLOAD(e->payload, req->payload_size, req->payload);
+    if (!response) {
+        response = is_l7_response(e, req, payload, size, total_size);
+        if (response == L7_RESPONSE_KEEP_REQUEST) {
+            return 0;
+        }
+    }
+    __u8 response_method = response ? response_payload_method(e) : METHOD_UNKNOWN;
+    if (response_method != METHOD_UNKNOWN && !send_response_payload(ctx, e, req, payload, size, response_method, cid, conn, 1)) {
+        bpf_map_delete_elem(&active_l7_requests, &k);
+        return 0;
+    }
+    bpf_map_delete_elem(&active_l7_requests, &k);
+    if (!response) {
+        return 0;
+    }
+    e->duration = bpf_ktime_get_ns() - req->ns;
+    send_event(ctx, e, cid, conn, 1);
+    return 0;
+}
+
 static inline __attribute__((__always_inline__))
 int trace_enter_write(void *ctx, __u64 fd, __u16 is_tls, char *buf, __u64 size, __u64 iovlen) {
     __u64 id = bpf_get_current_pid_tgid();
@@ -182,10 +427,15 @@ int trace_enter_write(void *ctx, __u64 fd, __u16 is_tls, char *buf, __u64 size,
     cid.pid = id >> 32;
     cid.fd = fd;
     __u64 total_size = size;
+    __u8 inbound = 0;
 
     struct connection *conn = bpf_map_lookup_elem(&active_connections, &cid);
     if (!conn) {
-        return 0;
+        conn = bpf_map_lookup_elem(&inbound_connections, &cid);
+        if (!conn) {
+            return 0;
+        }
+        inbound = 1;
     }
 
     char* payload = buf;
@@ -205,6 +455,10 @@ int trace_enter_write(void *ctx, __u64 fd, __u16 is_tls, char *buf, __u64 size,
         __sync_fetch_and_add(&conn->bytes_sent, total_size);
     }
 
+    if (inbound) {
+        return trace_inbound_response(ctx, cid, conn, is_tls, payload, size, total_size);
+    }
+
     struct l7_request *req = bpf_map_lookup_elem(&l7_request_heap, &zero);
     if (!req) {
         return 0;
@@ -232,7 +486,7 @@ int trace_enter_write(void *ctx, __u64 fd, __u16 is_tls, char *buf, __u64 size,
             e->method = METHOD_STATEMENT_CLOSE;
             e->payload_size = size;
             COPY_PAYLOAD(e->payload, size, payload);
-            send_event(ctx, e, cid, conn);
+            send_event(ctx, e, cid, conn, 0);
             return 0;
         }
         req->protocol = PROTOCOL_POSTGRES;
@@ -250,7 +504,7 @@ int trace_enter_write(void *ctx, __u64 fd, __u16 is_tls, char *buf, __u64 size,
             e->method = METHOD_STATEMENT_CLOSE;
             e->payload_size = size;
             COPY_PAYLOAD(e->payload, size, payload);
-            send_event(ctx, e, cid, conn);
+            send_event(ctx, e, cid, conn, 0);
             return 0;
         }
         req->protocol = PROTOCOL_MYSQL;
@@ -263,7 +517,9 @@ int trace_enter_write(void *ctx, __u64 fd, __u16 is_tls, char *buf, __u64 size,
         }
         e->protocol = PROTOCOL_RABBITMQ;
         e->method = METHOD_PRODUCE;
-        send_event(ctx, e, cid, conn);
+        e->payload_size = size;
+        COPY_PAYLOAD(e->payload, size, payload);
+        send_event(ctx, e, cid, conn, 0);
         return 0;
     } else if (nats_method(payload, size) == METHOD_PRODUCE) {
         struct l7_event *e = bpf_map_lookup_elem(&l7_event_heap, &zero);
@@ -272,7 +528,9 @@ int trace_enter_write(void *ctx, __u64 fd, __u16 is_tls, char *buf, __u64 size,
         }
         e->protocol = PROTOCOL_NATS;
         e->method = METHOD_PRODUCE;
-        send_event(ctx, e, cid, conn);
+        e->payload_size = size;
+        COPY_PAYLOAD(e->payload, size, payload);
+        send_event(ctx, e, cid, conn, 0);
         return 0;
     } else if (is_cassandra_request(payload, size, &k.stream_id)) {
         req->protocol = PROTOCOL_CASSANDRA;
@@ -286,7 +544,7 @@ int trace_enter_write(void *ctx, __u64 fd, __u16 is_tls, char *buf, __u64 size,
         e->duration = bpf_ktime_get_ns();
         e->payload_size = size;
         COPY_PAYLOAD(e->payload, size, payload);
-        send_event(ctx, e, cid, conn);
+        send_event(ctx, e, cid, conn, 0);
         return 0;
     } else if (is_clickhouse_query(payload, size)) {
         req->protocol = PROTOCOL_CLICKHOUSE;
@@ -300,8 +558,36 @@ int trace_enter_write(void *ctx, __u64 fd, __u16 is_tls, char *buf, __u64 size,
         }
     } else if (is_dubbo2_request(payload, size)) {
         req->protocol = PROTOCOL_DUBBO2;
+    } else if (is_mssql_query(payload, size)) {
+        req->protocol = PROTOCOL_MSSQL;
+    } else if (is_fastcgi_request(payload, size)) {
+        req->protocol = PROTOCOL_FASTCGI;
     } else if (is_dns_request(payload, size, &k.stream_id)) {
         req->protocol = PROTOCOL_DNS;
+    } else if (is_mqtt_packet(payload, size, METHOD_MQTT_CLIENT_PACKETS, cid, conn)) {
+        struct l7_event *e = bpf_map_lookup_elem(&l7_event_heap, &zero);
+        if (!e) {
+            return 0;
+        }
+        e->protocol = PROTOCOL_MQTT;
+        e->method = METHOD_MQTT_CLIENT_PACKETS;
+        e->duration = bpf_ktime_get_ns();
+        e->payload_size = size;
+        COPY_PAYLOAD(e->payload, size, payload);
+        send_event(ctx, e, cid, conn, 0);
+        return 0;
+    } else if (is_pulsar_frame(payload, size, METHOD_PULSAR_CLIENT_FRAMES)) {
+        struct l7_event *e = bpf_map_lookup_elem(&l7_event_heap, &zero);
+        if (!e) {
+            return 0;
+        }
+        e->protocol = PROTOCOL_PULSAR;
+        e->method = METHOD_PULSAR_CLIENT_FRAMES;
+        e->duration = bpf_ktime_get_ns();
+        e->payload_size = size;
+        COPY_PAYLOAD(e->payload, size, payload);
+        send_event(ctx, e, cid, conn, 0);
+        return 0;
     }
 
     if (req->protocol == PROTOCOL_UNKNOWN) {
@@ -323,7 +609,10 @@ int trace_enter_read(__u64 id, __u32 pid, __u64 fd, char *buf, __u64 *ret, __u64
 
     struct connection *conn = bpf_map_lookup_elem(&active_connections, &cid);
     if (!conn) {
-        return 0;
+        conn = bpf_map_lookup_elem(&inbound_connections, &cid);
+        if (!conn) {
+            return 0;
+        }
     }
 
     struct read_args args = {};
@@ -344,10 +633,15 @@ int trace_exit_read(void *ctx, __u64 id, __u32 pid, __u16 is_tls, long int ret)
     struct connection_id cid = {};
     cid.pid = pid;
     cid.fd = args->fd;
+    __u8 inbound = 0;
     struct connection *conn = bpf_map_lookup_elem(&active_connections, &cid);
     if (!conn) {
-        bpf_map_delete_elem(&active_reads, &id);
-        return 0;
+        conn = bpf_map_lookup_elem(&inbound_connections, &cid);
+        if (!conn) {
+            bpf_map_delete_elem(&active_reads, &id);
+            return 0;
+        }
+        inbound = 1;
     }
     struct l7_request_key k = {};
     k.pid = cid.pid;
@@ -387,6 +681,10 @@ int trace_exit_read(void *ctx, __u64 id, __u32 pid, __u16 is_tls, long int ret)
         __sync_fetch_and_add(&conn->bytes_received, total_size);
     }
 
+    if (inbound) {
+        return trace_inbound_request(ctx, cid, conn, is_tls, payload, ret, total_size);
+    }
+
     struct l7_event *e = bpf_map_lookup_elem(&l7_event_heap, &zero);
     if (!e) {
         return 0;
@@ -400,13 +698,17 @@ int trace_exit_read(void *ctx, __u64 id, __u32 pid, __u16 is_tls, long int ret)
     if (is_rabbitmq_consume(payload, ret)) {
         e->protocol = PROTOCOL_RABBITMQ;
         e->method = METHOD_CONSUME;
-        send_event(ctx, e, cid, conn);
+        e->payload_size = ret;
+        COPY_PAYLOAD(e->payload, ret, payload);
+        send_event(ctx, e, cid, conn, 0);
         return 0;
     }
     if (nats_method(payload, ret) == METHOD_CONSUME) {
         e->protocol = PROTOCOL_NATS;
         e->method = METHOD_CONSUME;
-        send_event(ctx, e, cid, conn);
+        e->payload_size = ret;
+        COPY_PAYLOAD(e->payload, ret, payload);
+        send_event(ctx, e, cid, conn, 0);
         return 0;
     }
 
@@ -422,14 +724,17 @@ int trace_exit_read(void *ctx, __u64 id, __u32 pid, __u16 is_tls, long int ret)
             e->duration = bpf_ktime_get_ns() - req->ns;
             e->payload_size = ret;
             COPY_PAYLOAD(e->payload, ret, payload);
-            send_event(ctx, e, cid, conn);
+            send_event(ctx, e, cid, conn, 0);
             bpf_map_delete_elem(&active_l7_requests, &k);
             return 0;
-        } else if (is_cassandra_response(payload, ret, &k.stream_id, &e->status)) {
+        } else if (is_cassandra_response(payload, ret, &k.stream_id, &e->status, &e->statement_id)) {
             req = bpf_map_lookup_elem(&active_l7_requests, &k);
             if (!req) {
                 return 0;
             }
+            if (e->statement_id) {
+                e->method = METHOD_STATEMENT_PREPARE;
+            }
             response = 1;
         } else if (looks_like_http2_frame(payload, ret, METHOD_HTTP2_SERVER_FRAMES)) {
             e->protocol = PROTOCOL_HTTP2;
@@ -437,7 +742,23 @@ int trace_exit_read(void *ctx, __u64 id, __u32 pid, __u16 is_tls, long int ret)
             e->duration = bpf_ktime_get_ns();
             e->payload_size = ret;
             COPY_PAYLOAD(e->payload, ret, payload);
-            send_event(ctx, e, cid, conn);
+            send_event(ctx, e, cid, conn, 0);
+            return 0;
+        } else if (is_mqtt_packet(payload, ret, METHOD_MQTT_SERVER_PACKETS, cid, conn)) {
+            e->protocol = PROTOCOL_MQTT;
+            e->method = METHOD_MQTT_SERVER_PACKETS;
+            e->duration = bpf_ktime_get_ns();
+            e->payload_size = ret;
+            COPY_PAYLOAD(e->payload, ret, payload);
+            send_event(ctx, e, cid, conn, 0);
+            return 0;
+        } else if (is_pulsar_frame(payload, ret, METHOD_PULSAR_SERVER_FRAMES)) {
+            e->protocol = PROTOCOL_PULSAR;
+            e->method = METHOD_PULSAR_SERVER_FRAMES;
+            e->duration = bpf_ktime_get_ns();
+            e->payload_size = ret;
+            COPY_PAYLOAD(e->payload, ret, payload);
+            send_event(ctx, e, cid, conn, 0);
             return 0;
         } else {
             return 0;
@@ -447,50 +768,23 @@ int trace_exit_read(void *ctx, __u64 id, __u32 pid, __u16 is_tls, long int ret)
     e->protocol = req->protocol;
     e->payload_size = req->payload_size;
     COPY_PAYLOAD(e->payload, req->payload_size, req->payload);
-    if (e->protocol == PROTOCOL_HTTP) {
-        response = is_http_response(payload, &e->status);
-    } else if (e->protocol == PROTOCOL_POSTGRES) {
-        response = is_postgres_response(payload, ret, &e->status);
-        if (req->request_type == POSTGRES_FRAME_PARSE) {
-            e->method = METHOD_STATEMENT_PREPARE;
-        }
-    } else if (e->protocol == PROTOCOL_REDIS) {
-        response = is_redis_response(payload, ret, &e->status);
-    } else if (e->protocol == PROTOCOL_MEMCACHED) {
-        response = is_memcached_response(payload, ret, &e->status);
-    } else if (e->protocol == PROTOCOL_MYSQL) {
-        response = is_mysql_response(payload, ret, req->request_type, &e->statement_id, &e->status);
-        if (req->request_type == MYSQL_COM_STMT_PREPARE) {
-            e->method = METHOD_STATEMENT_PREPARE;
-        }
-    } else if (e->protocol == PROTOCOL_MONGO) {
-        response = is_mongo_response(payload, ret, req->partial);
-        if (response == 2) { // partial
-            req->partial = 1;
-            return 0; // keeping the query in the map
-        }
-    } else if (e->protocol == PROTOCOL_KAFKA) {
-        response = is_kafka_response(payload, req->request_id);
-    } else if (e->protocol == PROTOCOL_CLICKHOUSE) {
-        response = is_clickhouse_response(payload, &e->status);
-        if (!response) {
-            return 0; // keeping the query in the map
-        }
-    } else if (e->protocol == PROTOCOL_ZOOKEEPER) {
-        response = is_zk_response(payload, total_size, &e->status, req->partial);
-        if (response == 2) { // partial
-            req->partial = 1;
+    if (!response) {
+        response = is_l7_response(e, req, payload, ret, total_size);
+        if (response == L7_RESPONSE_KEEP_REQUEST) {
             return 0; // keeping the query in the map
         }
-    } else if (e->protocol == PROTOCOL_DUBBO2) {
-        response = is_dubbo2_response(payload, &e->status);
+    }
+    __u8 response_method = response ? response_payload_method(e) : METHOD_UNKNOWN;
+    if (response_method != METHOD_UNKNOWN && !send_response_payload(ctx, e, req, payload, ret, response_method, cid, conn, 0)) {
+        bpf_map_delete_elem(&active_l7_requests, &k);
+        return 0;
     }
     bpf_map_delete_elem(&active_l7_requests, &k);
     if (!response) {
         return 0;
     }
     e->duration = bpf_ktime_get_ns() - req->ns;
-    send_event(ctx, e, cid, conn);
+    send_event(ctx, e, cid, conn, 0);
     return 0;
 }
 
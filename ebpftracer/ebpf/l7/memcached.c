@@ -1,7 +1,73 @@
 // https://github.com/memcached/memcached/blob/master/doc/protocol.txt
+// https://github.com/memcached/memcached/wiki/BinaryProtocolRevamped
+
+#define MEMCACHED_BINARY_REQUEST     0x80
+#define MEMCACHED_BINARY_RESPONSE    0x81
+#define MEMCACHED_BINARY_HEADER_SIZE 24
+#define MEMCACHED_BINARY_MAX_OPCODE  0x24
+
+#define MEMCACHED_STATUS_KEY_NOT_FOUND   0x01
+#define MEMCACHED_STATUS_KEY_EXISTS      0x02
+#define MEMCACHED_STATUS_ITEM_NOT_STORED 0x05
+#define MEMCACHED_STATUS_AUTH_CONTINUE   0x09
+
+struct memcached_binary_header {
+    __u8 magic;
+    __u8 opcode;
+    __u16 key_length;
+    __u8 extras_length;
+    __u8 data_type;
+    __u16 status; // vbucket id in requests
+    __u32 body_length;
+};
+
+static __always_inline
+int is_memcached_binary_request(char *buf, __u64 buf_size) {
+    struct memcached_binary_header h = {};
+    if (buf_size < MEMCACHED_BINARY_HEADER_SIZE) {
+        return 0;
+    }
+    bpf_read(buf, h);
+    if (h.magic != MEMCACHED_BINARY_REQUEST || h.opcode > MEMCACHED_BINARY_MAX_OPCODE || h.data_type != 0) {
+        return 0;
+    }
+    __u32 body_length = bpf_htonl(h.body_length);
+    if (MEMCACHED_BINARY_HEADER_SIZE + body_length > buf_size) {
+        return 0;
+    }
+    return bpf_htons(h.key_length) + h.extras_length <= body_length;
+}
+
+static __always_inline
+int is_memcached_binary_response(char *buf, __u64 buf_size, __s32 *status) {
+    struct memcached_binary_header h = {};
+    if (buf_size < MEMCACHED_BINARY_HEADER_SIZE) {
+        return 0;
+    }
+    bpf_read(buf, h);
+    if (h.magic != MEMCACHED_BINARY_RESPONSE || h.opcode > MEMCACHED_BINARY_MAX_OPCODE || h.data_type != 0) {
+        return 0;
+    }
+    switch (bpf_htons(h.status)) {
+    case 0:
+    case MEMCACHED_STATUS_KEY_NOT_FOUND:
+    case MEMCACHED_STATUS_KEY_EXISTS:
+    case MEMCACHED_STATUS_ITEM_NOT_STORED:
+    case MEMCACHED_STATUS_AUTH_CONTINUE:
+        *status = STATUS_OK;
+        break;
+    default:
+        *status = STATUS_FAILED;
+    }
+    return 1;
+}
+
 static __always_inline
 int is_memcached_query(char *buf, __u64 buf_size) {
-    if (buf_size < 9) {
+    if (is_memcached_binary_request(buf, buf_size)) {
+        return 1;
+    }
+    if (buf_size < 4) {
         return 0;
     }
     char b[7];
@@ -12,6 +78,15 @@ int is_memcached_query(char *buf, __u64 buf_size) {
     if (end[0] != '\r' || end[1] != '\n') {
         return 0;
     }
+    if (b[0] == 'm' && (b[1] == 'g' || b[1] == 's' || b[1] == 'd' || b[1] == 'a') && b[2] == ' ') { // meta commands
+        return 1;
+    }
+    if (b[0] == 'm' && b[1] == 'n' && b[2] == '\r') { // meta no-op
+        return 1;
+    }
+    if (buf_size < 9) {
+        return 0;
+    }
     if (b[0] == 's' && b[1] == 'e' && b[2] == 't' && b[3] == ' ') {
         return 1;
     }
@@ -50,6 +125,9 @@ int is_memcached_query(char *buf, __u64 buf_size) {
 
 static __always_inline
 int is_memcached_response(char *buf, __u64 buf_size, __s32 *status) {
+    if (is_memcached_binary_response(buf, buf_size, status)) {
+        return 1;
+    }
     char r[3];
     bpf_read(buf, r);
     char end[2];
@@ -94,6 +172,18 @@ int is_memcached_response(char *buf, __u64 buf_size, __s32 *status) {
         *status = STATUS_FAILED;
         return 1;
     }
+    if ((r[0] == 'H' && r[1] == 'D') || (r[0] == 'V' && r[1] == 'A' && r[2] == ' ')) { // meta: HD, VA <size>
+        *status = STATUS_OK;
+        return 1;
+    }
+    if ((r[0] == 'E' && (r[1] == 'N' || r[1] == 'X')) || (r[0] == 'N' && (r[1] == 'S' || r[1] == 'F'))) { // meta: EN, EX, NS, NF
+        *status = STATUS_OK;
+        return 1;
+    }
+    if (r[0] == 'M' && r[1] == 'N') { // meta: MN
+        *status = STATUS_OK;
+        return 1;
+    }
     if (r[0] >= '0' && r[0] <= '9') { // incr/decr response: <value>\r\n
         *status = STATUS_OK;
         return 1;
@@ -3,6 +3,9 @@
 #define MONGO_OP_COMPRESSED 2012
 #define MONGO_OP_MSG        2013
 
+#define MONGO_BSON_DOUBLE 0x01
+#define MONGO_BSON_INT32  0x10
+
 struct mongo_header {
     __s32 length;
     __s32 request_id;
@@ -10,6 +13,16 @@ struct mongo_header {
     __s32 op_code;
 };
 
+// The beginning of the OP_MSG body: the flag bits and the first element of the body section.
+struct mongo_msg_first_element {
+    __u32 flag_bits;
+    __u8 section_kind;
+    __s32 document_length;
+    __u8 type;
+    char name[3];
+    __u64 value;
+} __attribute__((packed));
+
 static __always_inline
 int is_mongo_query(char *buf, __u64 buf_size) {
     struct mongo_header h = {};
@@ -23,22 +36,52 @@ int is_mongo_query(char *buf, __u64 buf_size) {
     return 0;
 }
 
+// Error replies start with the ok field ({ok: 0, errmsg: ..., code: ..., codeName: ...}),
+// while successful replies have it after the result.
+static __always_inline
+int is_mongo_error_reply(char *buf, __u64 buf_size) {
+    struct mongo_msg_first_element e = {};
+    if (buf_size < sizeof(e)) {
+        return 0;
+    }
+    bpf_read(buf, e);
+    if (e.section_kind != 0 || e.name[0] != 'o' || e.name[1] != 'k' || e.name[2] != 0) {
+        return 0;
+    }
+    if (e.type == MONGO_BSON_DOUBLE) {
+        return e.value == 0;
+    }
+    if (e.type == MONGO_BSON_INT32) {
+        return (__u32)e.value == 0;
+    }
+    return 0;
+}
+
 static __always_inline
-int is_mongo_response(char *buf, __u64 buf_size, __u8 partial) {
+int is_mongo_response(char *buf, __u64 buf_size, __u8 partial, __s32 *status) {
     if (partial == 0 && buf_size == 4) { //partial read
         return 2;
     }
     struct mongo_header h = {};
+    __u64 header_size = sizeof(h);
     if (partial) {
         bpf_read(buf+4, h.response_to);
         bpf_read(buf+8, h.op_code);
+        header_size -= 4;
     } else {
         bpf_read(buf, h);
     }
     if (h.response_to == 0) {
         return 0;
     }
-    if (h.op_code == MONGO_OP_MSG || h.op_code == MONGO_OP_COMPRESSED) {
+    if (h.op_code == MONGO_OP_MSG) {
+        *status = STATUS_OK;
+        if (buf_size > header_size && is_mongo_error_reply(buf+header_size, buf_size-header_size)) {
+            *status = STATUS_FAILED;
+        }
+        return 1;
+    }
+    if (h.op_code == MONGO_OP_COMPRESSED) {
         return 1;
     }
     return 0;
@@ -0,0 +1,96 @@
+// https://docs.oasis-open.org/mqtt/mqtt/v3.1.1/mqtt-v3.1.1.html
+// https://docs.oasis-open.org/mqtt/mqtt/v5.0/mqtt-v5.0.html
+
+#define MQTT_PACKET_CONNECT     1
+#define MQTT_PACKET_CONNACK     2
+#define MQTT_PACKET_PUBLISH     3
+#define MQTT_PACKET_PUBACK      4
+#define MQTT_PACKET_PUBREC      5
+#define MQTT_PACKET_SUBSCRIBE   8
+#define MQTT_PACKET_SUBACK      9
+#define MQTT_PACKET_UNSUBSCRIBE 10
+#define MQTT_PACKET_UNSUBACK    11
+
+// The connections on which the client has sent a CONNECT packet.
+// The value is the timestamp of the connection to ignore the entries of closed connections whose fd has been reused.
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(key_size, sizeof(struct connection_id));
+    __uint(value_size, sizeof(__u64));
+    __uint(max_entries, 10240);
+} mqtt_connections SEC(".maps");
+
+// Only the packets the user space parser is interested in are reported:
+// CONNECT, PUBLISH, SUBSCRIBE and UNSUBSCRIBE sent by the client and
+// CONNACK, PUBLISH, PUBACK, PUBREC, SUBACK and UNSUBACK sent by the broker.
+// The fixed header is too short to tell MQTT from other protocols (e.g., a digit, '@' or 'P'),
+// so the packets other than CONNECT are reported only on the connections where a CONNECT has been seen.
+static __always_inline
+int is_mqtt_packet(char *buf, __u64 buf_size, __u8 method, struct connection_id cid, struct connection *conn) {
+    if (buf_size < 4) {
+        return 0;
+    }
+    __u8 b[8];
+    bpf_read(buf, b);
+    __u8 type = b[0] >> 4;
+    __u8 flags = b[0] & 0x0F;
+
+    if (type != MQTT_PACKET_CONNECT) {
+        __u64 *timestamp = bpf_map_lookup_elem(&mqtt_connections, &cid);
+        if (!timestamp || *timestamp != conn->timestamp) {
+            return 0;
+        }
+    }
+
+    // the remaining length is encoded with 1-4 bytes
+    __u32 remaining_length = 0;
+    __u32 offset = 0;
+    #pragma unroll
+    for (int i = 1; i <= 4; i++) {
+        remaining_length |= (b[i] & 0x7F) << (7 * (i-1));
+        if (!(b[i] & 0x80)) {
+            offset = i + 1;
+            break;
+        }
+    }
+    if (!offset || offset + remaining_length > buf_size) {
+        return 0;
+    }
+
+    switch (type) {
+    case MQTT_PACKET_PUBLISH:
+        if (((flags >> 1) & 0x03) == 0x03) { // QoS 3 is malformed
+            return 0;
+        }
+        __u16 topic_length;
+        bpf_read(buf+offset, topic_length);
+        topic_length = bpf_htons(topic_length);
+        return topic_length > 0 && topic_length + 2 <= remaining_length;
+    case MQTT_PACKET_CONNECT: {
+        if (method != METHOD_MQTT_CLIENT_PACKETS || flags != 0) {
+            return 0;
+        }
+        char name[8];
+        bpf_read(buf+offset, name);
+        int mqtt = name[0] == 0 && name[1] == 4 && name[2] == 'M' && name[3] == 'Q' && name[4] == 'T' && name[5] == 'T';
+        int mqisdp = name[0] == 0 && name[1] == 6 && name[2] == 'M' && name[3] == 'Q' && name[4] == 'I' && name[5] == 's'; // 3.1
+        if (!mqtt && !mqisdp) {
+            return 0;
+        }
+        __u64 timestamp = conn->timestamp;
+        bpf_map_update_elem(&mqtt_connections, &cid, &timestamp, BPF_ANY);
+        return 1;
+    }
+    case MQTT_PACKET_SUBSCRIBE:
+    case MQTT_PACKET_UNSUBSCRIBE:
+        return method == METHOD_MQTT_CLIENT_PACKETS && flags == 0x02 && remaining_length > 2;
+    case MQTT_PACKET_CONNACK:
+        return method == METHOD_MQTT_SERVER_PACKETS && flags == 0 && remaining_length >= 2 && b[2] <= 1;
+    case MQTT_PACKET_PUBACK:
+    case MQTT_PACKET_PUBREC:
+    case MQTT_PACKET_SUBACK:
+    case MQTT_PACKET_UNSUBACK:
+        return method == METHOD_MQTT_SERVER_PACKETS && flags == 0 && remaining_length >= 2;
+    }
+    return 0;
+}
@@ -0,0 +1,71 @@
+// Tabular Data Stream (TDS) protocol
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/b46a581a-39de-4745-b076-ec4dbb7d13ec
+
+#define MSSQL_PACKET_SQL_BATCH 0x01
+#define MSSQL_PACKET_RPC       0x03
+#define MSSQL_PACKET_RESPONSE  0x04
+
+#define MSSQL_STATUS_MASK      0x1F
+
+#define MSSQL_TOKEN_ERROR      0xAA
+#define MSSQL_TOKEN_DONE       0xFD
+#define MSSQL_TOKEN_DONEPROC   0xFE
+#define MSSQL_TOKEN_DONEINPROC 0xFF
+#define MSSQL_DONE_ERROR       0x02
+
+struct mssql_header {
+    __u8 type;
+    __u8 status;
+    __u16 length;
+    __u16 spid;
+    __u8 packet_id;
+    __u8 window;
+};
+
+static __always_inline
+int is_mssql_query(char *buf, __u64 buf_size) {
+    struct mssql_header h = {};
+    if (buf_size <= sizeof(h)) {
+        return 0;
+    }
+    bpf_read(buf, h);
+    if (h.type != MSSQL_PACKET_SQL_BATCH && h.type != MSSQL_PACKET_RPC) {
+        return 0;
+    }
+    if ((h.status & ~MSSQL_STATUS_MASK) != 0 || h.packet_id != 1 || h.window != 0) {
+        return 0;
+    }
+    __u16 length = bpf_ntohs(h.length);
+    if (length <= sizeof(h) || length > buf_size) {
+        return 0;
+    }
+    return 1;
+}
+
+static __always_inline
+int is_mssql_response(char *buf, __u64 buf_size, __s32 *status) {
+    struct mssql_header h = {};
+    if (buf_size <= sizeof(h)) {
+        return 0;
+    }
+    bpf_read(buf, h);
+    if (h.type != MSSQL_PACKET_RESPONSE || (h.status & ~MSSQL_STATUS_MASK) != 0 || h.window != 0) {
+        return 0;
+    }
+    __u8 token = 0;
+    bpf_read(buf+sizeof(h), token);
+    if (token == MSSQL_TOKEN_ERROR) {
+        *status = STATUS_FAILED;
+        return 1;
+    }
+    if (token == MSSQL_TOKEN_DONE || token == MSSQL_TOKEN_DONEPROC || token == MSSQL_TOKEN_DONEINPROC) {
+        __u16 done_status = 0;
+        bpf_read(buf+sizeof(h)+1, done_status);
+        if (done_status & MSSQL_DONE_ERROR) {
+            *status = STATUS_FAILED;
+            return 1;
+        }
+    }
+    *status = STATUS_OK;
+    return 1;
+}
@@ -0,0 +1,70 @@
+// https://pulsar.apache.org/docs/next/developing-binary-protocol/
+
+#define PULSAR_COMMAND_SUBSCRIBE        4
+#define PULSAR_COMMAND_PRODUCER         5
+#define PULSAR_COMMAND_SEND             6
+#define PULSAR_COMMAND_SEND_RECEIPT     7
+#define PULSAR_COMMAND_SEND_ERROR       8
+#define PULSAR_COMMAND_MESSAGE          9
+#define PULSAR_COMMAND_ACK              10
+#define PULSAR_COMMAND_CLOSE_PRODUCER   11
+#define PULSAR_COMMAND_CLOSE_CONSUMER   12
+#define PULSAR_COMMAND_SUCCESS          13
+#define PULSAR_COMMAND_ERROR            14
+#define PULSAR_COMMAND_PRODUCER_SUCCESS 17
+
+#define PULSAR_PROTOBUF_TYPE_TAG        0x08 // BaseCommand.type: field 1, varint
+
+// A frame consists of the total size, the command size and the BaseCommand protobuf message,
+// which starts with the command type followed by the command itself in the field with the same number.
+static __always_inline
+int is_pulsar_frame(char *buf, __u64 buf_size, __u8 method) {
+    if (buf_size < 12) {
+        return 0;
+    }
+    __u32 total_size;
+    bpf_read(buf, total_size);
+    total_size = bpf_htonl(total_size);
+    __u32 command_size;
+    bpf_read(buf+4, command_size);
+    command_size = bpf_htonl(command_size);
+    if (command_size < 4 || command_size + 4 > total_size || command_size + 8 > buf_size) {
+        return 0;
+    }
+    __u8 b[4];
+    bpf_read(buf+8, b);
+    if (b[0] != PULSAR_PROTOBUF_TYPE_TAG) {
+        return 0;
+    }
+    __u8 type = b[1];
+    switch (type) {
+    case PULSAR_COMMAND_SUBSCRIBE:
+    case PULSAR_COMMAND_PRODUCER:
+    case PULSAR_COMMAND_SEND:
+    case PULSAR_COMMAND_ACK:
+        if (method != METHOD_PULSAR_CLIENT_FRAMES) {
+            return 0;
+        }
+        break;
+    case PULSAR_COMMAND_SEND_RECEIPT:
+    case PULSAR_COMMAND_SEND_ERROR:
+    case PULSAR_COMMAND_MESSAGE:
+    case PULSAR_COMMAND_SUCCESS:
+    case PULSAR_COMMAND_ERROR:
+    case PULSAR_COMMAND_PRODUCER_SUCCESS:
+        if (method != METHOD_PULSAR_SERVER_FRAMES) {
+            return 0;
+        }
+        break;
+    case PULSAR_COMMAND_CLOSE_PRODUCER: // sent by the client or by the broker (e.g., when the topic is unloaded)
+    case PULSAR_COMMAND_CLOSE_CONSUMER:
+        break;
+    default:
+        return 0;
+    }
+    __u32 tag = (type << 3) | 2; // the length-delimited field, varint-encoded
+    if (tag < 0x80) {
+        return b[2] == tag;
+    }
+    return b[2] == ((tag & 0x7F) | 0x80) && b[3] == (tag >> 7);
+}
@@ -36,7 +36,12 @@ int is_redis_response(char *buf, __u64 buf_size, __s32 *status) {
         *status = STATUS_OK;
         return 1;
     }
-    if (type == '-') {
+    // RESP3: null, boolean, double, big number, verbatim string, map, set, attribute
+    if (type == '_' || type == '#' || type == ',' || type == '(' || type == '=' || type == '%' || type == '~' || type == '|') {
+        *status = STATUS_OK;
+        return 1;
+    }
+    if (type == '-' || type == '!') { // simple error or RESP3 blob error
         *status = STATUS_FAILED;
         return 1;
     }
@@ -83,6 +83,34 @@ struct {
     __uint(max_entries, MAX_CONNECTIONS);
 } active_connections SEC(".maps");
 
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(key_size, sizeof(struct connection_id));
+    __uint(value_size, sizeof(struct connection));
+    __uint(max_entries, MAX_CONNECTIONS);
+} inbound_connections SEC(".maps");
+
+struct sock_addrs {
+    __u16 sport;
+    __u16 dport;
+    __u8 saddr[16];
+    __u8 daddr[16];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(key_size, sizeof(void *));
+    __uint(value_size, sizeof(struct sock_addrs));
+    __uint(max_entries, 10240);
+} established_inbound_sockets SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(key_size, sizeof(__u64));
+    __uint(value_size, sizeof(struct sock_addrs));
+    __uint(max_entries, 10240);
+} accepted_sockets SEC(".maps");
+
 struct l7_request_key {
     __u64 fd;
     __u32 pid;
@@ -120,6 +148,16 @@ int inet_sock_set_state(void *ctx)
     __u64 id = bpf_get_current_pid_tgid();
     __u32 pid = id >> 32;
 
+    if (args.oldstate == BPF_TCP_SYN_RECV && args.newstate == BPF_TCP_ESTABLISHED) { // softirq context, the socket is not accepted yet
+        struct sock_addrs addrs = {};
+        addrs.sport = args.sport;
+        addrs.dport = args.dport;
+        __builtin_memcpy(&addrs.saddr, &args.saddr_v6, sizeof(addrs.saddr));
+        __builtin_memcpy(&addrs.daddr, &args.daddr_v6, sizeof(addrs.daddr));
+        bpf_map_update_elem(&established_inbound_sockets, &args.skaddr, &addrs, BPF_ANY);
+        return 0;
+    }
+
     if (args.oldstate == BPF_TCP_CLOSE && args.newstate == BPF_TCP_SYN_SENT) {
         __u64 *fdp = bpf_map_lookup_elem(&fd_by_pid_tgid, &id);
 
@@ -170,6 +208,7 @@ int inet_sock_set_state(void *ctx)
     }
     if (args.oldstate == BPF_TCP_ESTABLISHED && (args.newstate == BPF_TCP_FIN_WAIT1 || args.newstate == BPF_TCP_CLOSE_WAIT)) {
         bpf_map_delete_elem(&connection_id_by_socket, &args.skaddr);
+        bpf_map_delete_elem(&established_inbound_sockets, &args.skaddr);
     }
     if (args.oldstate == BPF_TCP_CLOSE && args.newstate == BPF_TCP_LISTEN) {
         type = EVENT_TYPE_LISTEN_OPEN;
@@ -255,6 +294,75 @@ int sys_exit_connect(struct trace_event_raw_sys_exit__stub* ctx) {
     return 0;
 }
 
+SEC("kretprobe/inet_csk_accept")
+int inet_csk_accept(struct pt_regs *ctx) {
+    void *sk = (void *)PT_REGS_RC(ctx);
+    if (!sk) {
+        return 0;
+    }
+    struct sock_addrs *addrs = bpf_map_lookup_elem(&established_inbound_sockets, &sk);
+    if (!addrs) {
+        return 0;
+    }
+    __u64 id = bpf_get_current_pid_tgid();
+    bpf_map_update_elem(&accepted_sockets, &id, addrs, BPF_ANY);
+    bpf_map_delete_elem(&established_inbound_sockets, &sk);
+    return 0;
+}
+
+static __always_inline
+int trace_exit_accept(void *ctx, long int ret) {
+    __u64 id = bpf_get_current_pid_tgid();
+    struct sock_addrs *addrs = bpf_map_lookup_elem(&accepted_sockets, &id);
+    if (!addrs) {
+        return 0;
+    }
+    if (ret < 0) {
+        bpf_map_delete_elem(&accepted_sockets, &id);
+        return 0;
+    }
+    struct connection_id cid = {};
+    cid.pid = id >> 32;
+    cid.fd = ret;
+
+    struct connection conn = {};
+    conn.timestamp = bpf_ktime_get_ns();
+    bpf_map_update_elem(&inbound_connections, &cid, &conn, BPF_ANY);
+
+    struct l7_request_key k = {
+        .fd = cid.fd,
+        .pid = cid.pid,
+        .is_tls = 0,
+        .stream_id = -1,
+    };
+    bpf_map_delete_elem(&active_l7_requests, &k);
+    k.is_tls = 1;
+    bpf_map_delete_elem(&active_l7_requests, &k);
+
+    struct tcp_event e = {};
+    e.type = EVENT_TYPE_CONNECTION_ACCEPT;
+    e.pid = cid.pid;
+    e.fd = cid.fd;
+    e.timestamp = conn.timestamp;
+    e.sport = addrs->sport;
+    e.dport = addrs->dport;
+    __builtin_memcpy(&e.saddr, &addrs->saddr, sizeof(e.saddr));
+    __builtin_memcpy(&e.daddr, &addrs->daddr, sizeof(e.daddr));
+    bpf_perf_event_output(ctx, &tcp_connect_events, BPF_F_CURRENT_CPU, &e, sizeof(e));
+    bpf_map_delete_elem(&accepted_sockets, &id);
+    return 0;
+}
+
+SEC("tracepoint/syscalls/sys_exit_accept")
+int sys_exit_accept(struct trace_event_raw_sys_exit__stub* ctx) {
+    return trace_exit_accept(ctx, ctx->ret);
+}
+
+SEC("tracepoint/syscalls/sys_exit_accept4")
+int sys_exit_accept4(struct trace_event_raw_sys_exit__stub* ctx) {
+    return trace_exit_accept(ctx, ctx->ret);
+}
+
 SEC("tracepoint/syscalls/sys_enter_close")
 int sys_enter_close(void *ctx) {
     struct trace_event_raw_args_with_fd__stub args = {};
@@ -276,6 +384,8 @@ int sys_enter_close(void *ctx) {
         e.timestamp = conn->timestamp;
         bpf_perf_event_output(ctx, &tcp_connect_events, BPF_F_CURRENT_CPU, &e, sizeof(e));
         bpf_map_delete_elem(&active_connections, &cid);
+        return 0;
     }
+    bpf_map_delete_elem(&inbound_connections, &cid);
     return 0;
 }
@@ -0,0 +1,166 @@
+package l7
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+// https://github.com/apache/cassandra/blob/trunk/doc/native_protocol_v4.spec
+
+const (
+	CassandraOpcodeQuery   = 0x07
+	CassandraOpcodePrepare = 0x09
+	CassandraOpcodeExecute = 0x0A
+	CassandraOpcodeBatch   = 0x0D
+
+	cassandraHeaderLength      = 9
+	cassandraFlagCompression   = 0x01
+	cassandraFlagCustomPayload = 0x04
+	cassandraBatchKindQuery    = 0
+	cassandraBatchKindPrepared = 1
+
+	cassandraMaxPreparedStatements = 1000
+)
+
+type CassandraParser struct {
+	preparedStatements *simplelru.LRU[uint32, string] // the least recently used statements are evicted
+	keyspace           string
+}
+
+func NewCassandraParser() *CassandraParser {
+	preparedStatements, _ := simplelru.NewLRU[uint32, string](cassandraMaxPreparedStatements, nil)
+	return &CassandraParser{preparedStatements: preparedStatements}
+}
+
+// Parse returns the statement and the keyspace in use on the connection.
+// Prepared statements are identified by the first 4 bytes of their ids.
+func (p *CassandraParser) Parse(payload []byte, statementId uint32) (string, string) {
+	if len(payload) < cassandraHeaderLength {
+		return "", ""
+	}
+	flags := payload[1]
+	opcode := payload[4]
+	if flags&cassandraFlagCompression != 0 {
+		return "", p.keyspace
+	}
+	body := payload[cassandraHeaderLength:]
+	if flags&cassandraFlagCustomPayload != 0 {
+		if body = cassandraSkipBytesMap(body); body == nil {
+			return "", p.keyspace
+		}
+	}
+	switch opcode {
+	case CassandraOpcodeQuery:
+		query := cassandraReadLongString(body)
+		if ks, ok := cassandraUseKeyspace(query); ok {
+			p.keyspace = ks
+		}
+		return query, p.keyspace
+	case CassandraOpcodePrepare:
+		query := cassandraReadLongString(body)
+		if query == "" {
+			return "", p.keyspace
+		}
+		if statementId != 0 {
+			p.preparedStatements.Add(statementId, query)
+		}
+		return "PREPARE " + query, p.keyspace
+	case CassandraOpcodeExecute:
+		return p.executeStatement(body), p.keyspace
+	case CassandraOpcodeBatch:
+		if len(body) < 4 {
+			return "", p.keyspace
+		}
+		n := binary.BigEndian.Uint16(body[1:])
+		body = body[3:]
+		var query string
+		switch body[0] {
+		case cassandraBatchKindQuery:
+			query = cassandraReadLongString(body[1:])
+		case cassandraBatchKindPrepared:
+			query = p.executeStatement(body[1:])
+		}
+		if query == "" {
+			return "", p.keyspace
+		}
+		if n > 1 {
+			return fmt.Sprintf("BEGIN BATCH %s; /* %d statements */ APPLY BATCH", query, n), p.keyspace
+		}
+		return fmt.Sprintf("BEGIN BATCH %s; APPLY BATCH", query), p.keyspace
+	}
+	return "", p.keyspace
+}
+
+func (p *CassandraParser) executeStatement(body []byte) string {
+	if len(body) < 2 {
+		return ""
+	}
+	l := int(binary.BigEndian.Uint16(body))
+	if l < 4 || len(body) < 2+l {
+		return ""
+	}
+	id := body[2 : 2+l]
+	statement, ok := p.preparedStatements.Get(binary.LittleEndian.Uint32(id))
+	if !ok {
+		statement = fmt.Sprintf(`EXECUTE %s /* unknown */`, hex.EncodeToString(id))
+	}
+	return statement
+}
+
+func cassandraReadLongString(body []byte) string {
+	if len(body) < 4 {
+		return ""
+	}
+	l := int(int32(binary.BigEndian.Uint32(body)))
+	if l <= 0 {
+		return ""
+	}
+	body = body[4:]
+	if l > len(body) {
+		return string(body) + "..."
+	}
+	return string(body[:l])
+}
+
+func cassandraSkipBytesMap(body []byte) []byte {
+	if len(body) < 2 {
+		return nil
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	body = body[2:]
+	for i := 0; i < n; i++ {
+		if len(body) < 2 {
+			return nil
+		}
+		l := 2 + int(binary.BigEndian.Uint16(body))
+		if len(body) < l+4 {
+			return nil
+		}
+		body = body[l:]
+		vl := int(int32(binary.BigEndian.Uint32(body)))
+		body = body[4:]
+		if vl > 0 {
+			if len(body) < vl {
+				return nil
+			}
+			body = body[vl:]
+		}
+	}
+	return body
+}
+
+func cassandraUseKeyspace(query string) (string, bool) {
+	fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "USE") {
+		return "", false
+	}
+	ks := fields[1]
+	if strings.HasPrefix(ks, `"`) && strings.HasSuffix(ks, `"`) && len(ks) > 1 {
+		return ks[1 : len(ks)-1], true
+	}
+	return strings.ToLower(ks), true
+}
@@ -0,0 +1,401 @@
+package l7
+
+import (
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	CloudProviderAWS   = "aws"
+	CloudProviderGCP   = "gcp"
+	CloudProviderAzure = "azure"
+)
+
+type CloudRequest struct {
+	Provider  string
+	Service   string // e.g., s3, dynamodb, sqs, gcs, azure_blob
+	Operation string // e.g., GetObject, PutItem, SendMessage, objects.get, GetBlob
+	Resource  string // the bucket, table, queue or container if known
+}
+
+type cloudHttpRequest struct {
+	method    string
+	path      string
+	query     url.Values
+	amzTarget string
+	body      []byte
+}
+
+// ParseCloudHttp recognizes the HTTP/1.x requests to the well-known AWS, GCP and Azure APIs
+// by the Host header, the path and the X-Amz-Target header. It returns nil for other requests.
+func ParseCloudHttp(payload []byte) *CloudRequest {
+	method, uri := ParseHttp(payload)
+	if method == "" {
+		return nil
+	}
+	var host, amzTarget string
+	body := httpHeaders(payload, func(name, value string) {
+		switch name {
+		case "host":
+			host = value
+		case "x-amz-target":
+			amzTarget = value
+		}
+	})
+	return ParseCloudRequest(method, host, uri, amzTarget, body)
+}
+
+// ParseCloudRequest recognizes the request to a cloud API by the HTTP method, host, URI, the X-Amz-Target header
+// and the (usually truncated) body.
+func ParseCloudRequest(method, host, uri, amzTarget string, body []byte) *CloudRequest {
+	if host == "" {
+		return nil
+	}
+	host = strings.ToLower(host)
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		host = h
+	}
+	r := cloudHttpRequest{method: method, amzTarget: amzTarget, body: body}
+	path, rawQuery, _ := strings.Cut(uri, "?")
+	r.path, _ = url.PathUnescape(path)
+	r.query, _ = url.ParseQuery(rawQuery)
+	switch {
+	case strings.HasSuffix(host, ".amazonaws.com"):
+		return parseAwsRequest(r, strings.TrimSuffix(host, ".amazonaws.com"))
+	case strings.HasSuffix(host, ".amazonaws.com.cn"):
+		return parseAwsRequest(r, strings.TrimSuffix(host, ".amazonaws.com.cn"))
+	case strings.HasSuffix(host, ".googleapis.com"):
+		return parseGcpRequest(r, strings.TrimSuffix(host, ".googleapis.com"))
+	case strings.HasSuffix(host, ".core.windows.net"):
+		return parseAzureRequest(r, strings.TrimSuffix(host, ".core.windows.net"))
+	}
+	return nil
+}
+
+// https://docs.aws.amazon.com/general/latest/gr/rande.html
+func parseAwsRequest(r cloudHttpRequest, prefix string) *CloudRequest {
+	labels := strings.Split(prefix, ".")
+	for i, l := range labels {
+		if l == "s3" || strings.HasPrefix(l, "s3-") { // s3.region, s3-region, s3-accelerate, bucket.s3.region
+			req := &CloudRequest{Provider: CloudProviderAWS, Service: "s3"}
+			bucket, key := strings.Join(labels[:i], "."), strings.TrimPrefix(r.path, "/")
+			if bucket == "" { // path-style
+				bucket, key, _ = strings.Cut(key, "/")
+			}
+			req.Resource = bucket
+			req.Operation = objectStorageOperation(r.method, bucket, key, r.query)
+			return req
+		}
+	}
+	// the regional (service.region) and global (service) endpoints only, other hosts are the customer's resources
+	// such as load balancers (*.elb), databases (*.rds), or EC2 instances (*.compute)
+	if len(labels) > 2 || strings.HasPrefix(labels[len(labels)-1], "compute") {
+		return nil
+	}
+	service := strings.TrimSuffix(labels[0], "-fips")
+	req := &CloudRequest{Provider: CloudProviderAWS, Service: service}
+	switch {
+	case r.amzTarget != "": // JSON protocol: DynamoDB_20120810.GetItem, AmazonSQS.SendMessage
+		_, req.Operation, _ = strings.Cut(r.amzTarget, ".")
+	case r.query.Get("Action") != "": // Query protocol
+		req.Operation = r.query.Get("Action")
+	case r.method == "POST" && len(r.body) > 0:
+		if form, err := url.ParseQuery(string(r.body)); err == nil {
+			req.Operation = form.Get("Action")
+		}
+	}
+	switch service {
+	case "dynamodb":
+		if table, ok := jsonStringField(r.body, "TableName"); ok {
+			req.Resource = table
+		}
+	case "sqs":
+		queueUrl, _ := jsonStringField(r.body, "QueueUrl")
+		if queueUrl == "" {
+			if form, err := url.ParseQuery(string(r.body)); err == nil {
+				queueUrl = form.Get("QueueUrl")
+			}
+		}
+		if queueUrl == "" {
+			queueUrl = r.path // https://sqs.region.amazonaws.com/account-id/queue-name
+		}
+		if i := strings.LastIndexByte(queueUrl, '/'); i >= 0 {
+			req.Resource = queueUrl[i+1:]
+		}
+	}
+	if req.Operation == "" {
+		req.Operation = r.method
+	}
+	return req
+}
+
+// https://cloud.google.com/storage/docs/json_api/v1
+func parseGcpRequest(r cloudHttpRequest, prefix string) *CloudRequest {
+	if bucket, ok := strings.CutSuffix(prefix, ".storage"); ok { // XML API, virtual-hosted style
+		return &CloudRequest{
+			Provider: CloudProviderGCP, Service: "gcs", Resource: bucket,
+			Operation: objectStorageOperation(r.method, bucket, strings.TrimPrefix(r.path, "/"), r.query),
+		}
+	}
+	if strings.Contains(prefix, ".") || prefix == "www" {
+		return nil
+	}
+	if prefix != "storage" {
+		// e.g., pubsub.googleapis.com/v1/projects/p/topics/t:publish
+		req := &CloudRequest{Provider: CloudProviderGCP, Service: prefix, Operation: r.method}
+		if i := strings.LastIndexByte(r.path, ':'); i >= 0 && !strings.Contains(r.path[i:], "/") {
+			req.Operation = r.path[i+1:]
+		}
+		return req
+	}
+	req := &CloudRequest{Provider: CloudProviderGCP, Service: "gcs"}
+	path := strings.TrimPrefix(r.path, "/")
+	api, path, _ := strings.Cut(path, "/")
+	switch api {
+	case "upload", "download":
+		_, path, _ = strings.Cut(path, "/")
+		fallthrough
+	case "storage":
+		parts := strings.SplitN(path, "/", 5) // v1/b/bucket/o/object
+		if len(parts) < 2 || parts[1] != "b" {
+			req.Operation = r.method
+			return req
+		}
+		switch {
+		case len(parts) == 2:
+			req.Operation = gcsOperation("buckets", r.method, true)
+		case len(parts) == 3:
+			req.Resource = parts[2]
+			req.Operation = gcsOperation("buckets", r.method, false)
+		case len(parts) == 4 && parts[3] == "o":
+			req.Resource = parts[2]
+			req.Operation = gcsOperation("objects", r.method, true)
+		default:
+			req.Resource = parts[2]
+			req.Operation = gcsOperation("objects", r.method, false)
+		}
+	default: // XML API, path style
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(r.path, "/"), "/")
+		req.Resource = bucket
+		req.Operation = objectStorageOperation(r.method, bucket, key, r.query)
+	}
+	return req
+}
+
+func gcsOperation(resource, method string, collection bool) string {
+	switch method {
+	case "GET":
+		if collection {
+			return resource + ".list"
+		}
+		return resource + ".get"
+	case "POST":
+		return resource + ".insert"
+	case "PUT":
+		return resource + ".update"
+	case "PATCH":
+		return resource + ".patch"
+	case "DELETE":
+		return resource + ".delete"
+	}
+	return method
+}
+
+// https://learn.microsoft.com/en-us/rest/api/storageservices/
+func parseAzureRequest(r cloudHttpRequest, prefix string) *CloudRequest {
+	account, service, ok := strings.Cut(prefix, ".")
+	if !ok || account == "" {
+		return nil
+	}
+	req := &CloudRequest{Provider: CloudProviderAzure, Operation: r.method}
+	resource, rest, _ := strings.Cut(strings.TrimPrefix(r.path, "/"), "/")
+	req.Resource = resource
+	comp, restype := r.query.Get("comp"), r.query.Get("restype")
+	switch service {
+	case "blob":
+		req.Service = "azure_blob"
+		switch {
+		case resource == "" && comp == "list":
+			req.Operation = "ListContainers"
+		case restype == "container" && comp == "list":
+			req.Operation = "ListBlobs"
+		case restype == "container":
+			req.Operation = azureOperation(r.method, "Container")
+		case rest != "" && comp == "block":
+			req.Operation = "PutBlock"
+		case rest != "" && comp == "blocklist" && r.method == "PUT":
+			req.Operation = "PutBlockList"
+		case rest != "" && comp == "":
+			req.Operation = azureOperation(r.method, "Blob")
+		}
+	case "queue":
+		req.Service = "azure_queue"
+		switch {
+		case rest == "messages" && r.method == "GET" && r.query.Get("peekonly") == "true":
+			req.Operation = "PeekMessages"
+		case rest == "messages" && r.method == "GET":
+			req.Operation = "GetMessages"
+		case rest == "messages" && r.method == "POST":
+			req.Operation = "PutMessage"
+		case rest == "messages" && r.method == "DELETE":
+			req.Operation = "ClearMessages"
+		case strings.HasPrefix(rest, "messages/") && r.method == "DELETE":
+			req.Operation = "DeleteMessage"
+		case strings.HasPrefix(rest, "messages/") && r.method == "PUT":
+			req.Operation = "UpdateMessage"
+		}
+	case "table":
+		req.Service = "azure_table"
+		req.Resource, _, _ = strings.Cut(resource, "(")
+		resource, collection := "Entity", "Entities"
+		if req.Resource == "Tables" {
+			req.Resource = ""
+			resource, collection = "Table", "Tables"
+		}
+		switch r.method {
+		case "GET":
+			req.Operation = "Query" + collection
+		case "POST":
+			req.Operation = "Insert" + resource
+		case "PUT":
+			req.Operation = "Update" + resource
+		case "MERGE":
+			req.Operation = "Merge" + resource
+		case "DELETE":
+			req.Operation = "Delete" + resource
+		}
+	default:
+		return nil
+	}
+	return req
+}
+
+func azureOperation(method, resource string) string {
+	switch method {
+	case "GET":
+		return "Get" + resource
+	case "HEAD":
+		return "Get" + resource + "Properties"
+	case "PUT":
+		if resource == "Container" {
+			return "CreateContainer"
+		}
+		return "Put" + resource
+	case "DELETE":
+		return "Delete" + resource
+	}
+	return method
+}
+
+// objectStorageOperation returns the S3 operation name, which is also used for the S3-compatible XML API of GCS
+func objectStorageOperation(method, bucket, key string, query url.Values) string {
+	switch {
+	case bucket == "":
+		if method == "GET" {
+			return "ListBuckets"
+		}
+	case key == "":
+		switch method {
+		case "GET":
+			if query.Has("uploads") {
+				return "ListMultipartUploads"
+			}
+			return "ListObjects"
+		case "HEAD":
+			return "HeadBucket"
+		case "PUT":
+			return "CreateBucket"
+		case "DELETE":
+			return "DeleteBucket"
+		case "POST":
+			if query.Has("delete") {
+				return "DeleteObjects"
+			}
+		}
+	default:
+		switch method {
+		case "GET":
+			return "GetObject"
+		case "HEAD":
+			return "HeadObject"
+		case "PUT":
+			if query.Has("uploadId") {
+				return "UploadPart"
+			}
+			return "PutObject"
+		case "DELETE":
+			if query.Has("uploadId") {
+				return "AbortMultipartUpload"
+			}
+			return "DeleteObject"
+		case "POST":
+			if query.Has("uploads") {
+				return "CreateMultipartUpload"
+			}
+			if query.Has("uploadId") {
+				return "CompleteMultipartUpload"
+			}
+		}
+	}
+	return method
+}
+
+// jsonStringField returns the unescaped value of the first string field with the given name found in the (possibly
+// truncated) JSON, and whether the value is complete
+func jsonStringField(data []byte, name string) (string, bool) {
+	key := []byte(`"` + name + `"`)
+	for {
+		i := bytes.Index(data, key)
+		if i < 0 {
+			return "", false
+		}
+		data = data[i+len(key):]
+		rest := bytes.TrimLeft(data, " \t\r\n")
+		if len(rest) == 0 || rest[0] != ':' {
+			continue
+		}
+		rest = bytes.TrimLeft(rest[1:], " \t\r\n")
+		if len(rest) == 0 || rest[0] != '"' {
+			continue
+		}
+		return jsonUnescape(rest[1:])
+	}
+}
+
+// jsonUnescape reads the string value following the opening quote
+func jsonUnescape(data []byte) (string, bool) {
+	var sb strings.Builder
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '"':
+			return sb.String(), true
+		case '\\':
+			if i++; i == len(data) {
+				return sb.String(), false
+			}
+			switch data[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'u':
+				if i+4 >= len(data) {
+					return sb.String(), false
+				}
+				if r, err := strconv.ParseUint(string(data[i+1:i+5]), 16, 32); err == nil {
+					sb.WriteRune(rune(r))
+				}
+				i += 4
+			default: // \" \\ \/
+				sb.WriteByte(data[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), false
+}
@@ -1,33 +1,73 @@
 package l7
 
 import (
+	"encoding/binary"
 	"strings"
 
 	"golang.org/x/net/dns/dnsmessage"
 	"inet.af/netaddr"
 )
 
-func ParseDns(payload []byte) (string, string, []netaddr.IP) {
+const dnsMaxCnameChain = 8
+
+type DnsResponse struct {
+	Type   string
+	Name   string       // the queried name
+	Cnames []string     // the chain of canonical names the queried name resolves to
+	IPs    []netaddr.IP // the addresses of the queried name or of the last canonical name
+}
+
+// ParseDns parses a DNS response received over UDP or TCP (prefixed with the length)
+func ParseDns(payload []byte) *DnsResponse {
 	var msg dnsmessage.Message
 	if err := msg.Unpack(payload); err != nil {
-		return "", "", nil
+		if len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) < len(payload)-2 {
+			return nil
+		}
+		if err = msg.Unpack(payload[2:]); err != nil {
+			return nil
+		}
 	}
 	if len(msg.Questions) < 1 {
-		return "", "", nil
+		return nil
+	}
+	q := msg.Questions[0]
+	res := &DnsResponse{Type: q.Type.String(), Name: strings.TrimSuffix(q.Name.String(), ".")}
+
+	// following the chain of CNAMEs starting from the queried name,
+	// the answers to other names (if any) are ignored
+	owner := q.Name.String()
+	for i := 0; i < dnsMaxCnameChain; i++ {
+		target := ""
+		for _, a := range msg.Answers {
+			if a.Header.Type != dnsmessage.TypeCNAME || !strings.EqualFold(a.Header.Name.String(), owner) {
+				continue
+			}
+			if c, ok := a.Body.(*dnsmessage.CNAMEResource); ok {
+				target = c.CNAME.String()
+				break
+			}
+		}
+		if target == "" {
+			break
+		}
+		res.Cnames = append(res.Cnames, strings.TrimSuffix(target, "."))
+		owner = target
 	}
-	var ips []netaddr.IP
 	for _, a := range msg.Answers {
+		if !strings.EqualFold(a.Header.Name.String(), owner) {
+			continue
+		}
 		switch a.Header.Type {
 		case dnsmessage.TypeA:
 			if r, ok := a.Body.(*dnsmessage.AResource); ok {
-				ips = append(ips, netaddr.IPFrom4(r.A))
+				res.IPs = append(res.IPs, netaddr.IPFrom4(r.A))
 			}
 		case dnsmessage.TypeAAAA:
 			if r, ok := a.Body.(*dnsmessage.AAAAResource); ok {
-				ips = append(ips, netaddr.IPFrom16(r.AAAA))
+				res.IPs = append(res.IPs, netaddr.IPFrom16(r.AAAA))
 			}
 		}
 	}
-	q := msg.Questions[0]
-	return q.Type.String(), strings.TrimSuffix(q.Name.String(), "."), ips
+	return res
 }
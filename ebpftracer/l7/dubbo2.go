@@ -0,0 +1,126 @@
+package l7
+
+import (
+	"encoding/binary"
+	"unicode/utf8"
+)
+
+// https://cn.dubbo.apache.org/zh-cn/overview/reference/protocols/tcp/
+// http://hessian.caucho.com/doc/hessian-serialization.html
+
+const (
+	dubbo2HeaderLength         = 16
+	dubbo2SerializationMask    = 0x1f
+	dubbo2SerializationHessian = 2
+)
+
+// Dubbo2Status is the status code of a Dubbo response (passed by the eBPF code in the statement_id field)
+type Dubbo2Status uint32
+
+func (s Dubbo2Status) String() string {
+	switch s {
+	case 20:
+		return "ok"
+	case 30:
+		return "client_timeout"
+	case 31:
+		return "server_timeout"
+	case 40:
+		return "bad_request"
+	case 50:
+		return "bad_response"
+	case 60:
+		return "service_not_found"
+	case 70:
+		return "service_error"
+	case 80:
+		return "server_error"
+	case 90:
+		return "client_error"
+	case 100:
+		return "server_threadpool_exhausted"
+	}
+	return "unknown"
+}
+
+// ParseDubbo2 returns the service interface and the method name of a Hessian2-encoded request
+func ParseDubbo2(payload []byte) (string, string) {
+	if len(payload) < dubbo2HeaderLength || payload[0] != 0xda || payload[1] != 0xbb {
+		return "", ""
+	}
+	if payload[2]&dubbo2SerializationMask != dubbo2SerializationHessian {
+		return "", ""
+	}
+	r := hessianReader{data: payload[dubbo2HeaderLength:]}
+	r.string() // dubbo version
+	service := r.string()
+	r.string() // service version
+	method := r.string()
+	if r.err {
+		return service, ""
+	}
+	return service, method
+}
+
+type hessianReader struct {
+	data []byte
+	err  bool
+}
+
+func (r *hessianReader) string() string {
+	if r.err || len(r.data) == 0 {
+		r.err = true
+		return ""
+	}
+	var res []byte
+	for {
+		if len(r.data) == 0 {
+			r.err = true
+			return string(res)
+		}
+		tag := r.data[0]
+		final := true
+		var l int
+		switch {
+		case tag <= 0x1f:
+			l = int(tag)
+			r.data = r.data[1:]
+		case tag >= 0x30 && tag <= 0x33:
+			if len(r.data) < 2 {
+				r.err = true
+				return string(res)
+			}
+			l = int(tag-0x30)<<8 | int(r.data[1])
+			r.data = r.data[2:]
+		case tag == 'S' || tag == 'R':
+			if len(r.data) < 3 {
+				r.err = true
+				return string(res)
+			}
+			l = int(binary.BigEndian.Uint16(r.data[1:]))
+			r.data = r.data[3:]
+			final = tag == 'S'
+		case tag == 'N':
+			r.data = r.data[1:]
+			return ""
+		default:
+			r.err = true
+			return ""
+		}
+		// the length is the number of characters, not bytes
+		n := 0
+		for i := 0; i < l; i++ {
+			if n >= len(r.data) {
+				r.err = true
+				return string(append(res, r.data...))
+			}
+			_, size := utf8.DecodeRune(r.data[n:])
+			n += size
+		}
+		res = append(res, r.data[:n]...)
+		r.data = r.data[n:]
+		if final {
+			return string(res)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package l7
+
+import (
+	"strings"
+)
+
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/rest-apis.html
+// https://opensearch.org/docs/latest/api-reference/
+
+type ElasticsearchRequest struct {
+	Operation string // search, msearch, count, bulk, mget, index, get, update or delete
+	Index     string // the target index, data stream or alias (may be a comma-separated list or a pattern)
+}
+
+var elasticsearchEndpoints = map[string]string{
+	"_search":  "search",
+	"_msearch": "msearch",
+	"_count":   "count",
+	"_bulk":    "bulk",
+	"_mget":    "mget",
+}
+
+// ParseElasticsearch recognizes the Elasticsearch/OpenSearch search and document APIs by the HTTP method and path.
+// It returns nil if the request is not an Elasticsearch API call.
+func ParseElasticsearch(method, path string) *ElasticsearchRequest {
+	if method == "" {
+		return nil
+	}
+	path, _, _ = strings.Cut(path, "?")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 1 {
+		if op := elasticsearchEndpoints[parts[0]]; op != "" {
+			return &ElasticsearchRequest{Operation: op}
+		}
+		return nil
+	}
+	index, endpoint := parts[0], parts[1]
+	if index == "" || index[0] == '_' {
+		return nil
+	}
+	switch len(parts) {
+	case 2:
+		if op := elasticsearchEndpoints[endpoint]; op != "" {
+			return &ElasticsearchRequest{Operation: op, Index: index}
+		}
+		if endpoint == "_doc" && method == "POST" { // the document ID is generated
+			return &ElasticsearchRequest{Operation: "index", Index: index}
+		}
+	case 3:
+		op := ""
+		switch endpoint {
+		case "_doc":
+			switch method {
+			case "GET", "HEAD":
+				op = "get"
+			case "PUT", "POST":
+				op = "index"
+			case "DELETE":
+				op = "delete"
+			}
+		case "_create":
+			op = "index"
+		case "_update":
+			op = "update"
+		case "_source":
+			op = "get"
+		}
+		if op != "" {
+			return &ElasticsearchRequest{Operation: op, Index: index}
+		}
+	}
+	return nil
+}
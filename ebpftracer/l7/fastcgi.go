@@ -0,0 +1,147 @@
+package l7
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+// https://fastcgi-archives.github.io/FastCGI_Specification.html
+
+const (
+	fastcgiHeaderLength = 8
+	fastcgiParams       = 4
+	fastcgiStdout       = 6
+)
+
+type FastcgiRequest struct {
+	Method string
+	Uri    string // REQUEST_URI or SCRIPT_NAME if the former is missing
+	Script string
+}
+
+// Path returns the URI without the query string
+func (r FastcgiRequest) Path() string {
+	path, _, _ := strings.Cut(r.Uri, "?")
+	return path
+}
+
+type FastcgiParser struct {
+	status Status
+}
+
+func NewFastcgiParser() *FastcgiParser {
+	return &FastcgiParser{}
+}
+
+// ParseResponse parses the status of the response that precedes the request: the Status header of the CGI response
+// written to STDOUT, or 200 if the header is missing.
+func (p *FastcgiParser) ParseResponse(payload []byte) {
+	p.status = StatusUnknown
+	if len(payload) < fastcgiHeaderLength || payload[1] != fastcgiStdout {
+		return
+	}
+	p.status = StatusOk
+	l := int(binary.BigEndian.Uint16(payload[4:]))
+	headers := payload[fastcgiHeaderLength:]
+	if len(headers) > l {
+		headers = headers[:l]
+	}
+	if end := bytes.Index(headers, []byte("\r\n\r\n")); end >= 0 {
+		headers = headers[:end]
+	}
+	for _, h := range bytes.Split(headers, crlf) {
+		name, value, ok := bytes.Cut(h, []byte(":"))
+		if !ok || !strings.EqualFold(string(name), "Status") {
+			continue
+		}
+		code, _, _ := bytes.Cut(bytes.TrimSpace(value), space)
+		if s, err := strconv.Atoi(string(code)); err == nil {
+			p.status = Status(s)
+		}
+		return
+	}
+}
+
+// Status returns the HTTP status of the request
+func (p *FastcgiParser) Status(status Status) Status {
+	s := p.status
+	p.status = StatusUnknown
+	if s == StatusUnknown {
+		return status
+	}
+	return s
+}
+
+// ParseFastcgi reads the request parameters from the PARAMS records following BEGIN_REQUEST
+func ParseFastcgi(payload []byte) FastcgiRequest {
+	var r FastcgiRequest
+	var scriptName string
+	for len(payload) >= fastcgiHeaderLength {
+		typ := payload[1]
+		l := int(binary.BigEndian.Uint16(payload[4:]))
+		padding := int(payload[6])
+		data := payload[fastcgiHeaderLength:]
+		truncated := len(data) < l
+		if !truncated {
+			data = data[:l]
+		}
+		if typ == fastcgiParams {
+			if l == 0 { // the end of the params stream
+				break
+			}
+			fastcgiReadParams(data, func(name, value string) {
+				switch name {
+				case "REQUEST_METHOD":
+					r.Method = value
+				case "REQUEST_URI":
+					r.Uri = value
+				case "SCRIPT_NAME":
+					scriptName = value
+				}
+			})
+		}
+		if truncated || len(payload) < fastcgiHeaderLength+l+padding {
+			break
+		}
+		payload = payload[fastcgiHeaderLength+l+padding:]
+	}
+	r.Script = scriptName
+	if r.Uri == "" {
+		r.Uri = scriptName
+	}
+	return r
+}
+
+// fastcgiReadParams reads name-value pairs: the lengths are encoded with 1 byte or 4 bytes with the high bit set
+func fastcgiReadParams(data []byte, f func(name, value string)) {
+	readLength := func() (int, bool) {
+		if len(data) < 1 {
+			return 0, false
+		}
+		if data[0]&0x80 == 0 {
+			l := int(data[0])
+			data = data[1:]
+			return l, true
+		}
+		if len(data) < 4 {
+			return 0, false
+		}
+		l := int(binary.BigEndian.Uint32(data) & 0x7fffffff)
+		data = data[4:]
+		return l, true
+	}
+	for len(data) > 0 {
+		nameLen, ok := readLength()
+		if !ok {
+			return
+		}
+		valueLen, ok := readLength()
+		if !ok || len(data) < nameLen+valueLen {
+			return
+		}
+		f(string(data[:nameLen]), string(data[nameLen:nameLen+valueLen]))
+		data = data[nameLen+valueLen:]
+	}
+}
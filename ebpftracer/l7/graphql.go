@@ -0,0 +1,159 @@
+package l7
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+)
+
+// https://graphql.github.io/graphql-over-http/draft/
+// https://spec.graphql.org/October2021/#sec-Document
+
+type GraphqlOperation struct {
+	Type string // query, mutation, subscription, or empty if the document is not sent (persisted queries)
+	Name string
+}
+
+// ParseGraphql extracts the operation from a GraphQL over HTTP request: from the JSON body of a POST request
+// (the first one of a batch) or from the query string of a GET request. It returns nil for other requests.
+func ParseGraphql(payload []byte) *GraphqlOperation {
+	method, uri := ParseHttp(payload)
+	var document, name string
+	switch method {
+	case "GET":
+		_, rawQuery, ok := strings.Cut(uri, "?")
+		if !ok {
+			return nil
+		}
+		params, _ := url.ParseQuery(rawQuery) // the params parsed before an error are returned anyway
+		if !params.Has("query") && !strings.Contains(params.Get("extensions"), `"persistedQuery"`) {
+			return nil
+		}
+		document, name = params.Get("query"), params.Get("operationName")
+	case "POST":
+		_, body, ok := bytes.Cut(payload, []byte("\r\n\r\n"))
+		if !ok {
+			return nil
+		}
+		body = bytes.TrimLeft(body, " \t\r\n[")
+		if len(body) == 0 || body[0] != '{' {
+			return nil
+		}
+		document, _ = jsonStringField(body, "query")
+		name, _ = jsonStringField(body, "operationName")
+		if document == "" && !bytes.Contains(body, []byte(`"persistedQuery"`)) {
+			return nil
+		}
+	default:
+		return nil
+	}
+	op := &GraphqlOperation{Name: name}
+	if document != "" {
+		typ, docName := graphqlOperation(document, name)
+		if typ == "" {
+			return nil
+		}
+		op.Type = typ
+		if op.Name == "" {
+			op.Name = docName
+		}
+	}
+	return op
+}
+
+// graphqlOperation returns the type and the name of the operation with the given name
+// or of the first operation defined in the (possibly truncated) document
+func graphqlOperation(document, name string) (string, string) {
+	var firstType, firstName string
+	depth := 0
+	definition := false // an operation or a fragment definition is being read
+	for i := 0; i < len(document); {
+		c := document[i]
+		switch {
+		case c == '#':
+			if end := strings.IndexByte(document[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(document)
+			}
+		case c == '"':
+			i = graphqlSkipString(document, i)
+		case c == '{' || c == '(' || c == '[':
+			if depth == 0 && c == '{' && !definition { // the query shorthand: { field }
+				if firstType == "" {
+					firstType = "query"
+				}
+			}
+			definition = false
+			depth++
+			i++
+		case c == '}' || c == ')' || c == ']':
+			if depth > 0 {
+				depth--
+			}
+			if depth == 0 && c == ')' {
+				definition = true // the selection set follows the variable definitions
+			}
+			i++
+		case isGraphqlNameStart(c):
+			token := graphqlName(document[i:])
+			i += len(token)
+			if depth > 0 {
+				continue
+			}
+			switch token {
+			case "query", "mutation", "subscription":
+				definition = true
+				for i < len(document) && strings.IndexByte(" \t\r\n,", document[i]) >= 0 {
+					i++
+				}
+				opName := graphqlName(document[i:])
+				i += len(opName)
+				if name != "" && opName == name {
+					return token, opName
+				}
+				if firstType == "" {
+					firstType, firstName = token, opName
+				}
+			case "fragment":
+				definition = true
+			}
+		default:
+			i++
+		}
+	}
+	return firstType, firstName
+}
+
+func graphqlSkipString(document string, i int) int {
+	if strings.HasPrefix(document[i:], `"""`) {
+		if end := strings.Index(document[i+3:], `"""`); end >= 0 {
+			return i + 3 + end + 3
+		}
+		return len(document)
+	}
+	for i++; i < len(document); i++ {
+		switch document[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(document)
+}
+
+func graphqlName(s string) string {
+	if len(s) == 0 || !isGraphqlNameStart(s[0]) {
+		return ""
+	}
+	i := 1
+	for i < len(s) && (isGraphqlNameStart(s[i]) || (s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	return s[:i]
+}
+
+func isGraphqlNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -2,6 +2,7 @@ package l7
 
 import (
 	"bytes"
+	"strings"
 )
 
 func ParseHttp(payload []byte) (string, string) {
@@ -18,3 +19,24 @@ func ParseHttp(payload []byte) (string, string) {
 	}
 	return string(method), string(uri)
 }
+
+// httpHeaders calls f with the lowercase name and the value of each header of the HTTP/1.x request
+// and returns the body if the end of the headers has been captured
+func httpHeaders(payload []byte, f func(name, value string)) []byte {
+	_, rest, _ := bytes.Cut(payload, crlf)
+	for {
+		line, r, ok := bytes.Cut(rest, crlf)
+		if !ok { // truncated
+			return nil
+		}
+		rest = r
+		if len(line) == 0 {
+			return rest
+		}
+		name, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		f(strings.ToLower(string(name)), string(bytes.TrimSpace(value)))
+	}
+}
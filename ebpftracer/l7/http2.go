@@ -24,13 +24,22 @@ type Http2FrameHeader struct {
 }
 
 type Http2Request struct {
-	Method   string
-	Path     string
-	Scheme   string
-	Status   Status
-	Duration time.Duration
+	Method     string
+	Path       string
+	Scheme     string
+	Authority  string
+	Status     Status
+	Duration   time.Duration
+	Grpc       bool
+	GrpcStatus GrpcStatus
+
+	kernelTime   uint64
+	traceHeaders TraceHeaders
+}
 
-	kernelTime uint64
+// TraceContext returns the trace context propagated in the request headers if any
+func (r *Http2Request) TraceContext() *TraceContext {
+	return r.traceHeaders.TraceContext()
 }
 
 type Http2Parser struct {
@@ -60,7 +69,8 @@ func (p *Http2Parser) Parse(method Method, payload []byte, kernelTime uint64) []
 	}
 
 	var decoder *hpack.Decoder
-	statuses := map[uint32]Status{}
+	responses := map[uint32]bool{} // stream_id -> end_stream
+	truncated := false
 	offset := 0
 
 	switch method {
@@ -75,6 +85,7 @@ func (p *Http2Parser) Parse(method Method, payload []byte, kernelTime uint64) []
 
 	for {
 		if len(payload)-offset < http2FrameHeaderLength {
+			truncated = offset < len(payload)
 			break
 		}
 		h := Http2FrameHeader{
@@ -86,6 +97,7 @@ func (p *Http2Parser) Parse(method Method, payload []byte, kernelTime uint64) []
 		offset += http2FrameHeaderLength
 		if h.Type != http2.FrameHeaders {
 			if len(payload)-offset < h.Length {
+				truncated = true
 				break
 			}
 			offset += h.Length
@@ -95,11 +107,15 @@ func (p *Http2Parser) Parse(method Method, payload []byte, kernelTime uint64) []
 		case MethodHttp2ClientFrames:
 			req := p.activeRequests[h.StreamId]
 			if req == nil {
-				req = &Http2Request{kernelTime: kernelTime}
+				req = &Http2Request{kernelTime: kernelTime, GrpcStatus: GrpcStatusUnknown}
 				p.activeRequests[h.StreamId] = req
 			}
 			decoder.SetEmitFunc(func(hf hpack.HeaderField) {
 				switch hf.Name {
+				case "content-type":
+					if isGrpcContentType(hf.Value) {
+						req.Grpc = true
+					}
 				case ":method":
 					if req.Method == "" && isHttpMethod(hf.Value) {
 						req.Method = hf.Value
@@ -112,22 +128,40 @@ func (p *Http2Parser) Parse(method Method, payload []byte, kernelTime uint64) []
 					if req.Scheme == "" && isHttpScheme(hf.Value) {
 						req.Scheme = hf.Value
 					}
+				case ":authority":
+					if req.Authority == "" {
+						req.Authority = hf.Value
+					}
+				default:
+					req.traceHeaders.Set(hf.Name, hf.Value)
 				}
 			})
 		case MethodHttp2ServerFrames:
-			if _, ok := statuses[h.StreamId]; !ok {
-				statuses[h.StreamId] = 0
+			responses[h.StreamId] = responses[h.StreamId] || h.Flags.Has(http2.FlagHeadersEndStream)
+			req := p.activeRequests[h.StreamId]
+			if req == nil {
+				req = &Http2Request{} // the headers must be decoded anyway to keep the decoder state consistent
 			}
 			decoder.SetEmitFunc(func(hf hpack.HeaderField) {
-				if hf.Name == ":status" {
+				switch hf.Name {
+				case ":status":
 					s, _ := strconv.Atoi(hf.Value)
-					statuses[h.StreamId] = Status(s)
+					req.Status = Status(s)
+				case "content-type":
+					if isGrpcContentType(hf.Value) {
+						req.Grpc = true
+					}
+				case "grpc-status":
+					if s, err := strconv.Atoi(hf.Value); err == nil {
+						req.GrpcStatus = GrpcStatus(s)
+					}
 				}
 			})
 		}
 		next := offset + h.Length
 		if next > len(payload) {
 			next = len(payload)
+			truncated = true
 		}
 		if _, err := decoder.Write(payload[offset:next]); err != nil {
 			continue
@@ -135,12 +169,16 @@ func (p *Http2Parser) Parse(method Method, payload []byte, kernelTime uint64) []
 		offset = next
 	}
 	var res []Http2Request
-	for streamId, status := range statuses {
+	for streamId, endStream := range responses {
 		r := p.activeRequests[streamId]
 		if r == nil {
 			continue
 		}
-		r.Status = status
+		// gRPC status is sent in trailers, which may arrive in one of the following writes.
+		// If the rest of the payload is truncated, there's no way to see the trailers.
+		if r.Grpc && r.GrpcStatus == GrpcStatusUnknown && !endStream && !truncated {
+			continue
+		}
 		r.Duration = time.Duration(kernelTime - r.kernelTime)
 		res = append(res, *r)
 		delete(p.activeRequests, streamId)
@@ -161,6 +199,19 @@ func (p *Http2Parser) Parse(method Method, payload []byte, kernelTime uint64) []
 	return res
 }
 
+func isGrpcContentType(s string) bool {
+	return strings.HasPrefix(s, "application/grpc")
+}
+
+// ParseGrpcPath splits a gRPC path (/package.Service/Method) into service and method names
+func ParseGrpcPath(path string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return "", ""
+	}
+	return service, method
+}
+
 func isHttpMethod(s string) bool {
 	switch s {
 	case http.MethodGet,
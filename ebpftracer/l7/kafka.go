@@ -0,0 +1,310 @@
+package l7
+
+import (
+	"encoding/binary"
+	"io"
+	"strconv"
+)
+
+// https://kafka.apache.org/protocol.html
+
+const (
+	KafkaApiKeyProduce     = 0
+	KafkaApiKeyFetch       = 1
+	KafkaApiKeyListOffsets = 2
+	KafkaApiKeyMetadata    = 3
+
+	kafkaMaxTopics = 32
+)
+
+var kafkaApiKeys = []string{
+	"Produce", "Fetch", "ListOffsets", "Metadata", "LeaderAndIsr", "StopReplica", "UpdateMetadata", "ControlledShutdown",
+	"OffsetCommit", "OffsetFetch", "FindCoordinator", "JoinGroup", "Heartbeat", "LeaveGroup", "SyncGroup", "DescribeGroups",
+	"ListGroups", "SaslHandshake", "ApiVersions", "CreateTopics", "DeleteTopics", "DeleteRecords", "InitProducerId",
+	"OffsetForLeaderEpoch", "AddPartitionsToTxn", "AddOffsetsToTxn", "EndTxn", "WriteTxnMarkers", "TxnOffsetCommit",
+	"DescribeAcls", "CreateAcls", "DeleteAcls", "DescribeConfigs", "AlterConfigs", "AlterReplicaLogDirs", "DescribeLogDirs",
+	"SaslAuthenticate", "CreatePartitions", "CreateDelegationToken", "RenewDelegationToken", "ExpireDelegationToken",
+	"DescribeDelegationToken", "DeleteGroups", "ElectLeaders", "IncrementalAlterConfigs", "AlterPartitionReassignments",
+	"ListPartitionReassignments", "OffsetDelete", "DescribeClientQuotas", "AlterClientQuotas", "DescribeUserScramCredentials",
+	"AlterUserScramCredentials", "Vote", "BeginQuorumEpoch", "EndQuorumEpoch", "DescribeQuorum", "AlterPartition",
+	"UpdateFeatures", "Envelope", "FetchSnapshot", "DescribeCluster", "DescribeProducers", "BrokerRegistration",
+	"BrokerHeartbeat", "UnregisterBroker", "DescribeTransactions", "ListTransactions", "AllocateProducerIds",
+	"ConsumerGroupHeartbeat",
+}
+
+// the first API version using the flexible (compact) encoding and the request header v2
+var kafkaFlexibleVersions = map[int16]int16{
+	KafkaApiKeyProduce:     9,
+	KafkaApiKeyFetch:       12,
+	KafkaApiKeyListOffsets: 6,
+	KafkaApiKeyMetadata:    9,
+}
+
+type KafkaRequest struct {
+	ApiKey     int16
+	ApiVersion int16
+	ClientId   string
+	Topics     []string
+}
+
+func (r *KafkaRequest) Operation() string {
+	if r == nil {
+		return ""
+	}
+	if r.ApiKey >= 0 && int(r.ApiKey) < len(kafkaApiKeys) {
+		return kafkaApiKeys[r.ApiKey]
+	}
+	return "ApiKey:" + strconv.Itoa(int(r.ApiKey))
+}
+
+func ParseKafka(payload []byte) *KafkaRequest {
+	r := &kafkaReader{data: payload}
+	r.skip(4) // length
+	req := &KafkaRequest{ApiKey: r.int16(), ApiVersion: r.int16()}
+	r.skip(4) // correlation_id
+	if r.err != nil || req.ApiKey < 0 || req.ApiVersion < 0 {
+		return nil
+	}
+	req.ClientId = r.string(false)
+	if r.err != nil {
+		return nil
+	}
+	flexible := false
+	if v, ok := kafkaFlexibleVersions[req.ApiKey]; ok && req.ApiVersion >= v {
+		flexible = true
+		r.taggedFields()
+	}
+	// the payload is usually truncated, so we collect the topics read before the end of the buffer
+	switch req.ApiKey {
+	case KafkaApiKeyProduce:
+		req.Topics = kafkaProduceTopics(r, req.ApiVersion, flexible)
+	case KafkaApiKeyFetch:
+		req.Topics = kafkaFetchTopics(r, req.ApiVersion, flexible)
+	case KafkaApiKeyListOffsets:
+		req.Topics = kafkaListOffsetsTopics(r, req.ApiVersion, flexible)
+	case KafkaApiKeyMetadata:
+		req.Topics = kafkaMetadataTopics(r, req.ApiVersion, flexible)
+	}
+	return req
+}
+
+func kafkaProduceTopics(r *kafkaReader, version int16, flexible bool) []string {
+	if version >= 3 {
+		r.string(flexible) // transactional_id
+	}
+	r.skip(2 + 4) // acks, timeout_ms
+	var topics []string
+	n := r.arrayLen(flexible)
+	for i := 0; i < n && r.err == nil; i++ {
+		topics = appendKafkaTopic(topics, r.string(flexible), r.err)
+		partitions := r.arrayLen(flexible)
+		for j := 0; j < partitions && r.err == nil; j++ {
+			r.skip(4) // index
+			r.bytes(flexible)
+			if flexible {
+				r.taggedFields()
+			}
+		}
+		if flexible {
+			r.taggedFields()
+		}
+	}
+	return topics
+}
+
+func kafkaFetchTopics(r *kafkaReader, version int16, flexible bool) []string {
+	if version < 15 {
+		r.skip(4) // replica_id
+	}
+	r.skip(4 + 4) // max_wait_ms, min_bytes
+	if version >= 3 {
+		r.skip(4) // max_bytes
+	}
+	if version >= 4 {
+		r.skip(1) // isolation_level
+	}
+	if version >= 7 {
+		r.skip(4 + 4) // session_id, session_epoch
+	}
+	if version >= 13 { // topics are identified by uuid
+		return nil
+	}
+	var topics []string
+	n := r.arrayLen(flexible)
+	for i := 0; i < n && r.err == nil; i++ {
+		topics = appendKafkaTopic(topics, r.string(flexible), r.err)
+		partitions := r.arrayLen(flexible)
+		for j := 0; j < partitions && r.err == nil; j++ {
+			r.skip(4) // partition
+			if version >= 9 {
+				r.skip(4) // current_leader_epoch
+			}
+			r.skip(8) // fetch_offset
+			if version >= 12 {
+				r.skip(4) // last_fetched_epoch
+			}
+			if version >= 5 {
+				r.skip(8) // log_start_offset
+			}
+			r.skip(4) // partition_max_bytes
+			if flexible {
+				r.taggedFields()
+			}
+		}
+		if flexible {
+			r.taggedFields()
+		}
+	}
+	return topics
+}
+
+func kafkaListOffsetsTopics(r *kafkaReader, version int16, flexible bool) []string {
+	r.skip(4) // replica_id
+	if version >= 2 {
+		r.skip(1) // isolation_level
+	}
+	var topics []string
+	n := r.arrayLen(flexible)
+	for i := 0; i < n && r.err == nil; i++ {
+		topics = appendKafkaTopic(topics, r.string(flexible), r.err)
+		partitions := r.arrayLen(flexible)
+		for j := 0; j < partitions && r.err == nil; j++ {
+			r.skip(4) // partition_index
+			if version >= 4 {
+				r.skip(4) // current_leader_epoch
+			}
+			r.skip(8) // timestamp
+			if version == 0 {
+				r.skip(4) // max_num_offsets
+			}
+			if flexible {
+				r.taggedFields()
+			}
+		}
+		if flexible {
+			r.taggedFields()
+		}
+	}
+	return topics
+}
+
+func kafkaMetadataTopics(r *kafkaReader, version int16, flexible bool) []string {
+	var topics []string
+	n := r.arrayLen(flexible) // -1 means all topics
+	for i := 0; i < n && r.err == nil; i++ {
+		if version >= 10 {
+			r.skip(16) // topic_id
+		}
+		topics = appendKafkaTopic(topics, r.string(flexible), r.err)
+		if flexible {
+			r.taggedFields()
+		}
+	}
+	return topics
+}
+
+func appendKafkaTopic(topics []string, topic string, err error) []string {
+	if err != nil || topic == "" || len(topics) >= kafkaMaxTopics {
+		return topics
+	}
+	for _, t := range topics {
+		if t == topic {
+			return topics
+		}
+	}
+	return append(topics, topic)
+}
+
+type kafkaReader struct {
+	data []byte
+	err  error
+}
+
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		r.data = nil
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *kafkaReader) skip(n int) {
+	r.next(n)
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = io.ErrUnexpectedEOF
+		r.data = nil
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// compact lengths are encoded as N+1, 0 means null
+func (r *kafkaReader) length(compact bool) int {
+	if compact {
+		return int(r.uvarint()) - 1
+	}
+	return int(r.int16())
+}
+
+func (r *kafkaReader) string(compact bool) string {
+	l := r.length(compact)
+	if l <= 0 {
+		return ""
+	}
+	return string(r.next(l))
+}
+
+func (r *kafkaReader) bytes(compact bool) {
+	var l int
+	if compact {
+		l = int(r.uvarint()) - 1
+	} else {
+		l = int(r.int32())
+	}
+	if l > 0 {
+		r.skip(l)
+	}
+}
+
+func (r *kafkaReader) arrayLen(compact bool) int {
+	if compact {
+		return int(r.uvarint()) - 1
+	}
+	return int(r.int32())
+}
+
+func (r *kafkaReader) taggedFields() {
+	n := r.uvarint()
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		r.uvarint() // tag
+		r.skip(int(r.uvarint()))
+	}
+}
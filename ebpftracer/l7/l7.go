@@ -23,6 +23,17 @@ const (
 	ProtocolDNS        Protocol = 13
 	ProtocolClickhouse Protocol = 14
 	ProtocolZookeeper  Protocol = 15
+
+	// gRPC is not detected by the eBPF code: it's identified by parsing HTTP/2 frames
+	ProtocolGrpc Protocol = 16
+
+	ProtocolMssql   Protocol = 17
+	ProtocolMqtt    Protocol = 18
+	ProtocolPulsar  Protocol = 19
+	ProtocolFastcgi Protocol = 20
+
+	// Elasticsearch is not detected by the eBPF code: it's identified by parsing HTTP requests
+	ProtocolElasticsearch Protocol = 21
 )
 
 func (p Protocol) String() string {
@@ -57,6 +68,18 @@ func (p Protocol) String() string {
 		return "ClickHouse"
 	case ProtocolZookeeper:
 		return "Zookeeper"
+	case ProtocolGrpc:
+		return "gRPC"
+	case ProtocolMssql:
+		return "MSSQL"
+	case ProtocolMqtt:
+		return "MQTT"
+	case ProtocolPulsar:
+		return "Pulsar"
+	case ProtocolFastcgi:
+		return "FastCGI"
+	case ProtocolElasticsearch:
+		return "Elasticsearch"
 	}
 	return "UNKNOWN:" + strconv.Itoa(int(p))
 }
@@ -64,13 +87,19 @@ func (p Protocol) String() string {
 type Method uint8
 
 const (
-	MethodUnknown           Method = 0
-	MethodProduce           Method = 1
-	MethodConsume           Method = 2
-	MethodStatementPrepare  Method = 3
-	MethodStatementClose    Method = 4
-	MethodHttp2ClientFrames Method = 5
-	MethodHttp2ServerFrames Method = 6
+	MethodUnknown            Method = 0
+	MethodProduce            Method = 1
+	MethodConsume            Method = 2
+	MethodStatementPrepare   Method = 3
+	MethodStatementClose     Method = 4
+	MethodHttp2ClientFrames  Method = 5
+	MethodHttp2ServerFrames  Method = 6
+	MethodErrorResponse      Method = 7
+	MethodResponse           Method = 8
+	MethodMqttClientPackets  Method = 9
+	MethodMqttServerPackets  Method = 10
+	MethodPulsarClientFrames Method = 11
+	MethodPulsarServerFrames Method = 12
 )
 
 func (m Method) String() string {
@@ -89,6 +118,18 @@ func (m Method) String() string {
 		return "http2_client_frames"
 	case MethodHttp2ServerFrames:
 		return "http2_server_frames"
+	case MethodErrorResponse:
+		return "error_response"
+	case MethodResponse:
+		return "response"
+	case MethodMqttClientPackets:
+		return "mqtt_client_packets"
+	case MethodMqttServerPackets:
+		return "mqtt_server_packets"
+	case MethodPulsarClientFrames:
+		return "pulsar_client_frames"
+	case MethodPulsarServerFrames:
+		return "pulsar_server_frames"
 	}
 	return "UNKNOWN:" + strconv.Itoa(int(m))
 }
@@ -143,6 +184,16 @@ func (s Status) DNS() string {
 		return "not_implemented"
 	case 5:
 		return "refused"
+	case 6:
+		return "yxdomain"
+	case 7:
+		return "yxrrset"
+	case 8:
+		return "nxrrset"
+	case 9:
+		return "notauth"
+	case 10:
+		return "notzone"
 	}
 	return ""
 }
@@ -157,6 +208,30 @@ func (s Status) Zookeeper() string {
 	return "ok"
 }
 
+type GrpcStatus int
+
+const GrpcStatusUnknown GrpcStatus = -1
+
+var grpcStatuses = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND", "ALREADY_EXISTS",
+	"PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+func (s GrpcStatus) String() string {
+	if s >= 0 && int(s) < len(grpcStatuses) {
+		return grpcStatuses[s]
+	}
+	if s == GrpcStatusUnknown {
+		return "unknown"
+	}
+	return strconv.Itoa(int(s))
+}
+
+func (s GrpcStatus) Error() bool {
+	return s > 0
+}
+
 func (s Status) Error() bool {
 	return s == StatusFailed
 }
@@ -168,4 +243,6 @@ type RequestData struct {
 	Method      Method
 	StatementId uint32
 	Payload     []byte
+	Inbound     bool
+	KernelTime  uint64 // the CLOCK_MONOTONIC time of the response (or of the frames for HTTP/2, MQTT and Pulsar)
 }
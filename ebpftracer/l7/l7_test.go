@@ -3,10 +3,19 @@ package l7
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+	"google.golang.org/protobuf/encoding/protowire"
+	"inet.af/netaddr"
 )
 
 func TestParseHttp(t *testing.T) {
@@ -27,6 +36,43 @@ func Test_parseMemcached(t *testing.T) {
 	cmd, items = ParseMemcached(append([]byte(`gets 1111 2222 3333`), '\r', '\n'))
 	assert.Equal(t, "gets", cmd)
 	assert.Equal(t, []string{"1111", "2222", "3333"}, items)
+
+	cmd, items = ParseMemcached([]byte("mg user:1 v t\r\nms user:2 5 T60\r\nhello\r\nmd user:3 q\r\nmn\r\n"))
+	assert.Equal(t, "mg", cmd)
+	assert.Equal(t, []string{"user:1", "user:2", "user:3"}, items)
+
+	binaryRequest := func(opcode byte, extras []byte, key, value string) []byte {
+		h := []byte{0x80, opcode, 0, byte(len(key)), byte(len(extras)), 0, 0, 0}
+		h = binary.BigEndian.AppendUint32(h, uint32(len(extras)+len(key)+len(value)))
+		h = append(h, make([]byte, 12)...) // opaque, cas
+		return append(append(append(h, extras...), key...), value...)
+	}
+	pipeline := append(binaryRequest(0x0d, nil, "k1", ""), binaryRequest(0x0d, nil, "k2", "")...)
+	pipeline = append(pipeline, binaryRequest(0x0a, nil, "", "")...)
+	cmd, items = ParseMemcached(pipeline)
+	assert.Equal(t, "getkq", cmd)
+	assert.Equal(t, []string{"k1", "k2"}, items)
+
+	cmd, items = ParseMemcached(binaryRequest(0x01, make([]byte, 8), "session:42", "value")[:30])
+	assert.Equal(t, "set", cmd)
+	assert.Nil(t, items)
+
+	p := NewMemcachedParser()
+	p.ParseErrorResponse([]byte("SERVER_ERROR out of memory storing object\r\n"))
+	status, msg := p.Status(StatusFailed)
+	assert.Equal(t, "server_error", status)
+	assert.Equal(t, "out of memory storing object", msg)
+
+	response := []byte{0x81, 0x01, 0, 0, 0, 0, 0, 0x82, 0, 0, 0, 13}
+	response = append(append(response, make([]byte, 12)...), "Out of memory"...)
+	p.ParseErrorResponse(response)
+	status, msg = p.Status(StatusFailed)
+	assert.Equal(t, "out_of_memory", status)
+	assert.Equal(t, "Out of memory", msg)
+
+	status, msg = p.Status(StatusFailed)
+	assert.Equal(t, "failed", status)
+	assert.Equal(t, "", msg)
 }
 
 func TestParseRedis(t *testing.T) {
@@ -54,6 +100,43 @@ func TestParseRedis(t *testing.T) {
 	assert.Equal(t, "mylist", args)
 }
 
+func TestParseRedisPipeline(t *testing.T) {
+	payload := []byte("*3\r\n$3\r\nset\r\n$3\r\nkey\r\n$4\r\nv\r\n1\r\n" +
+		"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n" +
+		"*1\r\n$4\r\nPING\r\n" +
+		"*2\r\n$3\r\nGET\r\n$10\r\ntrunc")
+	assert.Equal(t,
+		[]RedisCommand{{Cmd: "SET", Args: "key ..."}, {Cmd: "GET", Args: "key"}, {Cmd: "PING"}, {Cmd: "GET"}},
+		ParseRedisPipeline(payload))
+
+	assert.Nil(t, ParseRedisPipeline([]byte("*1\r\n$4\r\nPI")))
+}
+
+func TestRedisParserStatus(t *testing.T) {
+	p := NewRedisParser()
+	status, e := p.Status(StatusOk)
+	assert.Equal(t, "ok", status)
+	assert.Equal(t, "", e)
+
+	p.ParseErrorResponse([]byte("-MOVED 3999 127.0.0.1:6381\r\n"))
+	status, e = p.Status(StatusFailed)
+	assert.Equal(t, "moved", status)
+	assert.Equal(t, "MOVED", e)
+
+	p.ParseErrorResponse([]byte("-ERR unknown command 'FOO'\r\n"))
+	status, e = p.Status(StatusFailed)
+	assert.Equal(t, "failed", status)
+	assert.Equal(t, "ERR", e)
+
+	p.ParseErrorResponse([]byte("!40\r\nWRONGTYPE Operation against a key holding\r\n"))
+	status, _ = p.Status(StatusFailed)
+	assert.Equal(t, "wrongtype", status)
+
+	status, e = p.Status(StatusFailed)
+	assert.Equal(t, "failed", status)
+	assert.Equal(t, "", e)
+}
+
 type mongoHeader struct {
 	MessageLength int32
 	RequestID     int32
@@ -89,6 +172,39 @@ func TestParseMongo(t *testing.T) {
 	assert.Equal(t, `<truncated>`, ParseMongo(payload))
 }
 
+func TestParseMongoCommand(t *testing.T) {
+	msg := func(doc bson.D) []byte {
+		data, err := bson.Marshal(doc)
+		assert.NoError(t, err)
+		buf := bytes.NewBuffer(nil)
+		h := mongoHeader{MessageLength: 16 + 4 + 1 + int32(len(data)), OpCode: MongoOpMSG}
+		assert.NoError(t, binary.Write(buf, binary.LittleEndian, h))
+		buf.Write(data)
+		return buf.Bytes()
+	}
+
+	payload := msg(bson.D{{Key: "find", Value: "orders"}, {Key: "filter", Value: bson.D{{Key: "status", Value: "new"}}}, {Key: "limit", Value: int32(10)}, {Key: "$db", Value: "shop"}})
+	assert.Equal(t, &MongoCommand{Name: "find", Db: "shop", Collection: "orders"}, ParseMongoCommand(payload))
+	assert.Equal(t, &MongoCommand{Name: "find", Collection: "orders"}, ParseMongoCommand(payload[:len(payload)-10]))
+
+	payload = msg(bson.D{{Key: "getMore", Value: int64(12345)}, {Key: "collection", Value: "orders"}, {Key: "$db", Value: "shop"}})
+	assert.Equal(t, &MongoCommand{Name: "getMore", Db: "shop", Collection: "orders"}, ParseMongoCommand(payload))
+
+	payload = msg(bson.D{{Key: "aggregate", Value: int32(1)}, {Key: "pipeline", Value: bson.A{}}, {Key: "$db", Value: "admin"}})
+	assert.Equal(t, &MongoCommand{Name: "aggregate", Db: "admin"}, ParseMongoCommand(payload))
+
+	assert.Nil(t, ParseMongoCommand(payload[:20]))
+
+	reply := msg(bson.D{{Key: "ok", Value: 0.0}, {Key: "errmsg", Value: "E11000 duplicate key error"}, {Key: "code", Value: int32(11000)}, {Key: "codeName", Value: "DuplicateKey"}})
+	assert.Equal(t, &MongoReply{Ok: false, Code: 11000, CodeName: "DuplicateKey", ErrMsg: "E11000 duplicate key error"}, ParseMongoReply(reply))
+	assert.Equal(t, &MongoReply{Ok: false, Code: 11000, CodeName: "DuplicateKey", ErrMsg: "E11000 duplicate key error"}, ParseMongoReply(reply[4:]))
+
+	p := NewMongoParser()
+	p.ParseReply(msg(bson.D{{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}}}, {Key: "ok", Value: 1.0}}))
+	assert.Equal(t, &MongoReply{Ok: true}, p.Reply())
+	assert.Nil(t, p.Reply())
+}
+
 func TestParseClickHouse(t *testing.T) {
 	payload := []byte{
 		0x1, 0x24, 0x65, 0x38, 0x30, 0x63, 0x38, 0x31, 0x39, 0x62, 0x2d, 0x63, 0x33, 0x65, 0x33, 0x2d, 0x34, 0x66, 0x39,
@@ -271,3 +387,719 @@ func TestParseZookeeper(t *testing.T) {
 	assert.Equal(t, "getData", op)
 	assert.Equal(t, "/clickhouse/tables/shard-1/coroot_3kuq8b3z/otel_t...<TRUNCATED>", arg)
 }
+
+func TestParseKafka(t *testing.T) {
+	i16 := func(b []byte, v int16) []byte { return binary.BigEndian.AppendUint16(b, uint16(v)) }
+	i32 := func(b []byte, v int32) []byte { return binary.BigEndian.AppendUint32(b, uint32(v)) }
+	str := func(b []byte, s string) []byte { return append(i16(b, int16(len(s))), s...) }
+	compactStr := func(b []byte, s string) []byte { return append(append(b, byte(len(s)+1)), s...) }
+	header := func(apiKey, apiVersion int16, clientId string) []byte {
+		return str(i32(i16(i16(i32(nil, 0), apiKey), apiVersion), 1), clientId)
+	}
+
+	payload := header(0, 7, "producer-1")
+	payload = i16(payload, -1)         // transactional_id
+	payload = i32(i16(payload, 1), 10) // acks, timeout_ms
+	payload = i32(payload, 1)          // topics
+	payload = str(payload, "orders")
+	payload = i32(payload, 1) // partitions
+	payload = append(i32(i32(payload, 0), 4), 1, 2, 3, 4)
+	r := ParseKafka(payload)
+	assert.Equal(t, "Produce", r.Operation())
+	assert.Equal(t, int16(7), r.ApiVersion)
+	assert.Equal(t, "producer-1", r.ClientId)
+	assert.Equal(t, []string{"orders"}, r.Topics)
+
+	payload = header(0, 9, "producer-2")
+	payload = append(payload, 0)       // tagged fields
+	payload = append(payload, 0)       // transactional_id
+	payload = i32(i16(payload, 1), 10) // acks, timeout_ms
+	payload = append(payload, 3)       // topics
+	payload = compactStr(payload, "orders")
+	payload = append(payload, 2) // partitions
+	payload = append(i32(payload, 0), 5, 1, 2, 3, 4, 0)
+	payload = append(payload, 0)
+	payload = compactStr(payload, "payments")
+	payload = append(payload, 2)
+	payload = append(i32(payload, 0), 5, 1, 2) // truncated
+	r = ParseKafka(payload)
+	assert.Equal(t, "Produce", r.Operation())
+	assert.Equal(t, "producer-2", r.ClientId)
+	assert.Equal(t, []string{"orders", "payments"}, r.Topics)
+
+	payload = header(1, 11, "consumer")
+	payload = i32(i32(i32(i32(payload, -1), 500), 1), 1024) // replica_id, max_wait_ms, min_bytes, max_bytes
+	payload = i32(i32(append(payload, 0), 0), -1)           // isolation_level, session_id, session_epoch
+	payload = i32(payload, 2)
+	payload = str(payload, "events")
+	payload = i32(payload, 1)
+	payload = append(payload, make([]byte, 4+4+8+8+4)...)
+	payload = append(i16(payload, 6), "eve"...) // truncated
+	r = ParseKafka(payload)
+	assert.Equal(t, "Fetch", r.Operation())
+	assert.Equal(t, []string{"events"}, r.Topics)
+
+	payload = header(3, 12, "admin")
+	payload = append(payload, 0)
+	payload = append(payload, 2)
+	payload = append(payload, make([]byte, 16)...)
+	payload = compactStr(payload, "logs")
+	payload = append(payload, 0)
+	r = ParseKafka(payload)
+	assert.Equal(t, "Metadata", r.Operation())
+	assert.Equal(t, []string{"logs"}, r.Topics)
+
+	r = ParseKafka(header(12, 4, "consumer"))
+	assert.Equal(t, "Heartbeat", r.Operation())
+	assert.Empty(t, r.Topics)
+
+	assert.Nil(t, ParseKafka([]byte{0, 0, 0, 1, 0}))
+}
+
+func TestParseCassandra(t *testing.T) {
+	frame := func(opcode byte, body ...byte) []byte {
+		return append(binary.BigEndian.AppendUint32([]byte{0x04, 0, 0, 1, opcode}, uint32(len(body))), body...)
+	}
+	longString := func(s string) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, uint32(len(s))), s...)
+	}
+	p := NewCassandraParser()
+
+	q, ks := p.Parse(frame(CassandraOpcodeQuery, longString("USE shop;")...), 0)
+	assert.Equal(t, "USE shop;", q)
+	assert.Equal(t, "shop", ks)
+
+	q, ks = p.Parse(frame(CassandraOpcodeQuery, append(longString("SELECT * FROM orders"), 0, 1, 0)...), 0)
+	assert.Equal(t, "SELECT * FROM orders", q)
+	assert.Equal(t, "shop", ks)
+
+	id := []byte{0xde, 0xad, 0xbe, 0xef, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	q, _ = p.Parse(frame(CassandraOpcodePrepare, longString("SELECT * FROM orders WHERE id = ?")...), binary.LittleEndian.Uint32(id))
+	assert.Equal(t, "PREPARE SELECT * FROM orders WHERE id = ?", q)
+
+	execute := append([]byte{0, byte(len(id))}, id...)
+	q, ks = p.Parse(frame(CassandraOpcodeExecute, append(execute, 0, 1, 0)...), 0)
+	assert.Equal(t, "SELECT * FROM orders WHERE id = ?", q)
+	assert.Equal(t, "shop", ks)
+
+	q, _ = p.Parse(frame(CassandraOpcodeBatch, append([]byte{0, 0, 2, 1}, execute...)...), 0)
+	assert.Equal(t, "BEGIN BATCH SELECT * FROM orders WHERE id = ?; /* 2 statements */ APPLY BATCH", q)
+
+	unknown := []byte{0, 4, 1, 2, 3, 4}
+	q, _ = p.Parse(frame(CassandraOpcodeExecute, unknown...), 0)
+	assert.Equal(t, "EXECUTE 01020304 /* unknown */", q)
+
+	q, _ = p.Parse(frame(CassandraOpcodeQuery, longString("SELECT * FROM orders")[:10]...), 0)
+	assert.Equal(t, "SELECT...", q)
+
+	for i := uint32(1); i <= cassandraMaxPreparedStatements; i++ {
+		p.Parse(frame(CassandraOpcodePrepare, longString(fmt.Sprintf("SELECT %d", i))...), i)
+	}
+	q, _ = p.Parse(frame(CassandraOpcodeExecute, append(execute, 0, 1, 0)...), 0)
+	assert.Equal(t, "EXECUTE deadbeef0102030405060708090a0b0c /* unknown */", q)
+	q, _ = p.Parse(frame(CassandraOpcodeExecute, 0, 4, 1, 0, 0, 0), 0)
+	assert.Equal(t, "SELECT 1", q)
+}
+
+func TestParseRabbitmq(t *testing.T) {
+	shortString := func(s string) []byte {
+		return append([]byte{byte(len(s))}, s...)
+	}
+	frame := func(method uint16, args ...byte) []byte {
+		payload := []byte{1, 0, 1}
+		payload = binary.BigEndian.AppendUint32(payload, uint32(4+len(args)))
+		payload = binary.BigEndian.AppendUint16(payload, 60)
+		payload = binary.BigEndian.AppendUint16(payload, method)
+		return append(append(payload, args...), 0xCE)
+	}
+
+	args := append([]byte{0, 0}, shortString("orders")...)
+	args = append(args, shortString("order.created")...)
+	exchange, routingKey := ParseRabbitmq(frame(40, append(args, 0)...))
+	assert.Equal(t, "orders", exchange)
+	assert.Equal(t, "order.created", routingKey)
+
+	args = shortString("amq.ctag-1")
+	args = append(args, 0, 0, 0, 0, 0, 0, 0, 1, 0)
+	args = append(args, shortString("")...)
+	args = append(args, shortString("tasks")...)
+	exchange, routingKey = ParseRabbitmq(frame(60, args...))
+	assert.Equal(t, "", exchange)
+	assert.Equal(t, "tasks", routingKey)
+
+	exchange, routingKey = ParseRabbitmq(frame(10))
+	assert.Equal(t, "", exchange)
+	assert.Equal(t, "", routingKey)
+}
+
+func TestParseNats(t *testing.T) {
+	assert.Equal(t, "orders.created", ParseNats([]byte("PUB orders.created 5\r\nhello\r\n")))
+	assert.Equal(t, "orders.created", ParseNats([]byte("HPUB orders.created INBOX.1 22 33\r\nNATS/1.0\r\n")))
+	assert.Equal(t, "orders.created", ParseNats([]byte("MSG orders.created 9 INBOX.1 5\r\nhello\r\n")))
+	assert.Equal(t, "", ParseNats([]byte("PING\r\n")))
+}
+
+func TestParseDubbo2(t *testing.T) {
+	payload := []byte{0xda, 0xbb, 0xc2, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+	payload = append(payload, 0x05)
+	payload = append(payload, "2.0.2"...)
+	payload = append(payload, 0x30, 0x21)
+	payload = append(payload, "org.apache.dubbo.demo.DemoService"...)
+	payload = append(payload, 0x05)
+	payload = append(payload, "0.0.0"...)
+	payload = append(payload, 0x08)
+	payload = append(payload, "sayHello"...)
+	payload = append(payload, 0x12)
+	payload = append(payload, "Ljava/lang/String;"...)
+
+	service, method := ParseDubbo2(payload)
+	assert.Equal(t, "org.apache.dubbo.demo.DemoService", service)
+	assert.Equal(t, "sayHello", method)
+
+	service, method = ParseDubbo2(payload[:60])
+	assert.Equal(t, "org.apache.dubbo.demo.DemoService", service)
+	assert.Equal(t, "", method)
+
+	payload[2] = 0xc6 // fastjson
+	service, method = ParseDubbo2(payload)
+	assert.Equal(t, "", service)
+	assert.Equal(t, "", method)
+
+	assert.Equal(t, "ok", Dubbo2Status(20).String())
+	assert.Equal(t, "service_not_found", Dubbo2Status(60).String())
+	assert.Equal(t, "unknown", Dubbo2Status(0).String())
+}
+
+func TestParseGrpc(t *testing.T) {
+	clientBuf, serverBuf := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	clientEnc, serverEnc := hpack.NewEncoder(clientBuf), hpack.NewEncoder(serverBuf)
+	headers := func(enc *hpack.Encoder, buf *bytes.Buffer, streamId uint32, endStream bool, fields ...string) []byte {
+		buf.Reset()
+		for i := 0; i < len(fields); i += 2 {
+			assert.NoError(t, enc.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]}))
+		}
+		frames := bytes.NewBuffer(nil)
+		assert.NoError(t, http2.NewFramer(frames, nil).WriteHeaders(http2.HeadersFrameParam{
+			StreamID: streamId, BlockFragment: buf.Bytes(), EndStream: endStream, EndHeaders: true,
+		}))
+		return frames.Bytes()
+	}
+	data := func(streamId uint32, d []byte) []byte {
+		frames := bytes.NewBuffer(nil)
+		assert.NoError(t, http2.NewFramer(frames, nil).WriteData(streamId, false, d))
+		return frames.Bytes()
+	}
+	request := func(streamId uint32) []byte {
+		return headers(clientEnc, clientBuf, streamId, false,
+			":method", "POST", ":scheme", "http", ":path", "/helloworld.Greeter/SayHello", "content-type", "application/grpc",
+		)
+	}
+
+	p := NewHttp2Parser()
+	assert.Empty(t, p.Parse(MethodHttp2ClientFrames, request(1), 100))
+	payload := headers(serverEnc, serverBuf, 1, false, ":status", "200", "content-type", "application/grpc")
+	payload = append(payload, data(1, []byte{0, 0, 0, 0, 0})...)
+	assert.Empty(t, p.Parse(MethodHttp2ServerFrames, payload, 200))
+	res := p.Parse(MethodHttp2ServerFrames, headers(serverEnc, serverBuf, 1, true, "grpc-status", "14"), 300)
+	assert.Len(t, res, 1)
+	assert.True(t, res[0].Grpc)
+	assert.Equal(t, Status(200), res[0].Status)
+	assert.Equal(t, "UNAVAILABLE", res[0].GrpcStatus.String())
+	assert.Equal(t, time.Duration(200), res[0].Duration)
+	service, method := ParseGrpcPath(res[0].Path)
+	assert.Equal(t, "helloworld.Greeter", service)
+	assert.Equal(t, "SayHello", method)
+
+	// trailers-only response
+	assert.Empty(t, p.Parse(MethodHttp2ClientFrames, request(3), 400))
+	res = p.Parse(MethodHttp2ServerFrames, headers(serverEnc, serverBuf, 3, true, ":status", "200", "content-type", "application/grpc", "grpc-status", "0"), 500)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "OK", res[0].GrpcStatus.String())
+	assert.False(t, res[0].GrpcStatus.Error())
+}
+
+func TestParsePostgresErrorResponse(t *testing.T) {
+	frame := func(cmd byte, body ...byte) []byte {
+		return append(binary.BigEndian.AppendUint32([]byte{cmd}, uint32(len(body)+4)), body...)
+	}
+	fields := func(kv ...string) []byte {
+		var b []byte
+		for i := 0; i < len(kv); i += 2 {
+			b = append(append(append(b, kv[i][0]), kv[i+1]...), 0)
+		}
+		return append(b, 0)
+	}
+	p := NewPostgresParser()
+	assert.Nil(t, p.Error())
+
+	p.ParseErrorResponse(append(frame('2'), frame('E', fields("S", "ERREUR", "V", "ERROR", "C", "40P01", "M", "deadlock detected")...)...))
+	e := p.Error()
+	assert.Equal(t, &PostgresError{Severity: "ERROR", Code: "40P01", Message: "deadlock detected"}, e)
+	assert.Equal(t, "40", e.Class())
+	assert.Nil(t, p.Error())
+	assert.Equal(t, "", (*PostgresError)(nil).Class())
+
+	p.ParseErrorResponse(frame('E', fields("S", "FATAL", "C", "57P01")...))
+	assert.Equal(t, &PostgresError{Severity: "FATAL", Code: "57P01"}, p.Error())
+}
+
+func TestPostgresTransaction(t *testing.T) {
+	p := NewPostgresParser()
+	now := time.Now()
+	at := func(ms int) time.Time {
+		return now.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	outcome, idle := p.Transaction("SELECT 1", false, at(0), at(1))
+	assert.Equal(t, "", outcome)
+	assert.Equal(t, time.Duration(0), idle)
+
+	outcome, _ = p.Transaction("BEGIN", false, at(10), at(11))
+	assert.Equal(t, "", outcome)
+	outcome, idle = p.Transaction("UPDATE accounts SET balance = 0", false, at(111), at(112))
+	assert.Equal(t, "", outcome)
+	assert.Equal(t, 100*time.Millisecond, idle)
+	outcome, _ = p.Transaction("ROLLBACK TO SAVEPOINT s1", false, at(112), at(113))
+	assert.Equal(t, "", outcome)
+	outcome, idle = p.Transaction("commit;", false, at(123), at(124))
+	assert.Equal(t, PostgresTransactionCommit, outcome)
+	assert.Equal(t, 10*time.Millisecond, idle)
+
+	outcome, idle = p.Transaction("SELECT 1", false, at(200), at(201))
+	assert.Equal(t, "", outcome)
+	assert.Equal(t, time.Duration(0), idle)
+
+	p.Transaction("PREPARE s1 AS START TRANSACTION ISOLATION LEVEL SERIALIZABLE", false, at(300), at(301))
+	outcome, _ = p.Transaction("COMMIT", true, at(301), at(302))
+	assert.Equal(t, PostgresTransactionRollback, outcome)
+
+	p.Transaction("BEGIN", false, at(400), at(401))
+	outcome, _ = p.Transaction("ROLLBACK", false, at(401), at(402))
+	assert.Equal(t, PostgresTransactionRollback, outcome)
+
+	outcome, _ = p.Transaction("ROLLBACK", false, at(500), at(501))
+	assert.Equal(t, "", outcome)
+
+	// COMMIT succeeds at the protocol level, but the failed transaction is rolled back
+	p.Transaction("BEGIN", false, at(600), at(601))
+	p.Transaction("UPDATE accounts SET balance = 0", true, at(601), at(602))
+	outcome, _ = p.Transaction("COMMIT", false, at(602), at(603))
+	assert.Equal(t, PostgresTransactionRollback, outcome)
+
+	p.Transaction("BEGIN", false, at(700), at(701))
+	p.Transaction("SAVEPOINT s1", false, at(701), at(702))
+	p.Transaction("INSERT INTO accounts VALUES (1)", true, at(702), at(703))
+	p.Transaction("ROLLBACK TO SAVEPOINT s1", false, at(703), at(704))
+	outcome, _ = p.Transaction("COMMIT", false, at(704), at(705))
+	assert.Equal(t, PostgresTransactionCommit, outcome)
+
+	outcome, _ = p.Transaction("SELECT 1", true, at(800), at(801))
+	assert.Equal(t, "", outcome)
+	p.Transaction("BEGIN", false, at(802), at(803))
+	outcome, _ = p.Transaction("COMMIT", false, at(803), at(804))
+	assert.Equal(t, PostgresTransactionCommit, outcome)
+}
+
+func TestParseMssql(t *testing.T) {
+	ucs2 := func(s string) []byte {
+		var b []byte
+		for _, r := range s {
+			b = binary.LittleEndian.AppendUint16(b, uint16(r))
+		}
+		return b
+	}
+	packet := func(typ byte, data []byte) []byte {
+		allHeaders := append(binary.LittleEndian.AppendUint32(nil, 22), make([]byte, 18)...)
+		data = append(allHeaders, data...)
+		return append(binary.BigEndian.AppendUint16([]byte{typ, 0x01}, uint16(8+len(data))), append([]byte{0, 0, 1, 0}, data...)...)
+	}
+	nvarchar := func(s string) []byte {
+		v := ucs2(s)
+		b := append([]byte{0, 0, mssqlTypeNVarChar}, binary.LittleEndian.AppendUint16(nil, 8000)...)
+		b = append(b, 0x09, 0x04, 0xd0, 0x00, 0x34) // collation
+		return append(binary.LittleEndian.AppendUint16(b, uint16(len(v))), v...)
+	}
+
+	batch := packet(MssqlPacketSqlBatch, ucs2("SELECT * FROM orders"))
+	assert.Equal(t, "SELECT * FROM orders", ParseMssql(batch))
+	assert.Equal(t, "SELECT * FROM ...", ParseMssql(batch[:len(batch)-12]))
+
+	rpc := packet(MssqlPacketRpc, append([]byte{0xff, 0xff, 10, 0, 0, 0}, append(nvarchar("SELECT * FROM orders WHERE id = @p0"), nvarchar("@p0 int")...)...))
+	assert.Equal(t, "SELECT * FROM orders WHERE id = @p0", ParseMssql(rpc))
+
+	handle := []byte{0, 1, mssqlTypeIntN, 4, 4, 1, 0, 0, 0}
+	rpc = packet(MssqlPacketRpc, append(append([]byte{0xff, 0xff, 13, 0, 0, 0}, handle...), append(nvarchar("@p0 int"), nvarchar("DELETE FROM orders WHERE id = @p0")...)...))
+	assert.Equal(t, "DELETE FROM orders WHERE id = @p0", ParseMssql(rpc))
+	assert.Equal(t, "DELETE FROM o...", ParseMssql(rpc[:len(rpc)-40]))
+
+	rpc = packet(MssqlPacketRpc, append(append([]byte{10, 0}, ucs2("dbo.Report")...), 0, 0))
+	assert.Equal(t, "EXEC dbo.Report", ParseMssql(rpc))
+
+	msg := ucs2("Transaction (Process ID 52) was deadlocked")
+	token := binary.LittleEndian.AppendUint16([]byte{mssqlTokenError}, uint16(4+1+1+2+len(msg)+1+1+4))
+	token = binary.LittleEndian.AppendUint32(token, 1205)
+	token = append(token, 51, 13)
+	token = binary.LittleEndian.AppendUint16(token, uint16(len(msg)/2))
+	token = append(token, msg...)
+	done := append([]byte{mssqlTokenDone, 0x02, 0}, make([]byte, 10)...)
+	response := append(binary.BigEndian.AppendUint16([]byte{MssqlPacketResponse, 0x01}, 0), append([]byte{0, 0, 1, 0}, append(token, done...)...)...)
+	p := NewMssqlParser()
+	p.ParseErrorResponse(response)
+	assert.Equal(t, &MssqlError{Number: 1205, Class: 13, Message: "Transaction (Process ID 52) was deadlocked"}, p.Error())
+	assert.Nil(t, p.Error())
+}
+
+func TestParseMqtt(t *testing.T) {
+	packet := func(header byte, data ...byte) []byte {
+		return append([]byte{header, byte(len(data))}, data...)
+	}
+	str := func(s string) []byte {
+		return append([]byte{0, byte(len(s))}, s...)
+	}
+	ms := uint64(time.Millisecond)
+
+	p := NewMqttParser()
+	connect := packet(0x10, append(str("MQTT"), 4, 0x02, 0, 60, 0, 0)...)
+	assert.Nil(t, p.Parse(MethodMqttClientPackets, connect, 1*ms))
+	assert.Equal(t,
+		[]MqttRequest{{Packet: "CONNECT", Status: "bad_username_or_password", Failed: true, Duration: 2 * time.Millisecond, kernelTime: 1 * ms}},
+		p.Parse(MethodMqttServerPackets, packet(0x20, 0, 4), 3*ms))
+
+	qos0 := packet(0x30, append(str("sensors/1"), "21.5"...)...)
+	qos1 := packet(0x32, append(append(str("sensors/2"), 0, 7), "22"...)...)
+	assert.Equal(t,
+		[]MqttRequest{{Packet: "PUBLISH", Method: MethodProduce, Topics: []string{"sensors/1"}, Status: "ok", kernelTime: 10 * ms}},
+		p.Parse(MethodMqttClientPackets, append(qos0, qos1...), 10*ms))
+
+	subscribe := packet(0x82, append(append(append([]byte{0, 8}, str("cmd/#")...), 1), append(str("alerts"), 0)...)...)
+	assert.Nil(t, p.Parse(MethodMqttClientPackets, subscribe, 11*ms))
+
+	res := p.Parse(MethodMqttServerPackets, append(append(packet(0x40, 0, 7), packet(0x90, 0, 8, 1, 0x80)...), qos0[:8]...), 15*ms)
+	assert.Equal(t, []MqttRequest{
+		{Packet: "PUBLISH", Method: MethodProduce, Topics: []string{"sensors/2"}, Qos: 1, Status: "ok", Duration: 5 * time.Millisecond, kernelTime: 10 * ms},
+		{Packet: "SUBSCRIBE", Topics: []string{"cmd/#", "alerts"}, Status: "failed", Failed: true, Duration: 4 * time.Millisecond, kernelTime: 11 * ms},
+	}, res)
+
+	p = NewMqttParser()
+	assert.Nil(t, p.Parse(MethodMqttClientPackets, packet(0x10, append(str("MQTT"), 5, 0x02, 0, 60, 0, 0, 0)...), 1*ms))
+	assert.Nil(t, p.Parse(MethodMqttClientPackets, packet(0x34, append(append(str("jobs"), 0, 1, 0), "x"...)...), 2*ms))
+	assert.Equal(t,
+		[]MqttRequest{{Packet: "PUBLISH", Method: MethodProduce, Topics: []string{"jobs"}, Qos: 2, Status: "not_authorized", Failed: true, Duration: time.Millisecond, kernelTime: 2 * ms}},
+		p.Parse(MethodMqttServerPackets, append(packet(0x20, 0, 0, 0), packet(0x50, 0, 1, 0x87)...), 3*ms)[1:])
+}
+
+func TestParsePulsar(t *testing.T) {
+	frame := func(typ protowire.Number, fields ...[]byte) []byte {
+		cmd := bytes.Join(fields, nil)
+		base := protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), uint64(typ))
+		base = protowire.AppendBytes(protowire.AppendTag(base, typ, protowire.BytesType), cmd)
+		f := binary.BigEndian.AppendUint32(nil, uint32(4+len(base)))
+		f = binary.BigEndian.AppendUint32(f, uint32(len(base)))
+		return append(f, base...)
+	}
+	varint := func(num protowire.Number, v uint64) []byte {
+		return protowire.AppendVarint(protowire.AppendTag(nil, num, protowire.VarintType), v)
+	}
+	str := func(num protowire.Number, s string) []byte {
+		return protowire.AppendString(protowire.AppendTag(nil, num, protowire.BytesType), s)
+	}
+	ms := uint64(time.Millisecond)
+	orders := "persistent://public/default/orders"
+
+	p := NewPulsarParser()
+	assert.Nil(t, p.Parse(MethodPulsarClientFrames, frame(PulsarCommandProducer, str(1, orders), varint(2, 0), varint(3, 1)), 1*ms))
+	assert.Nil(t, p.Parse(MethodPulsarClientFrames, frame(PulsarCommandSubscribe, str(1, "unknown"), str(2, "sub"), varint(4, 0), varint(5, 2)), 1*ms))
+	assert.Equal(t,
+		[]PulsarRequest{
+			{Command: "PRODUCER", Topic: orders, Status: "ok", Duration: 2 * time.Millisecond, kernelTime: 1 * ms},
+			{Command: "SUBSCRIBE", Topic: "unknown", Status: "TopicNotFound", Failed: true, Error: "Topic does not exist", Duration: 2 * time.Millisecond, kernelTime: 1 * ms},
+		},
+		p.Parse(MethodPulsarServerFrames, append(
+			frame(PulsarCommandProducerSuccess, varint(1, 1), str(2, "producer-1")),
+			frame(PulsarCommandError, varint(1, 2), varint(2, 11), str(3, "Topic does not exist"))...,
+		), 3*ms))
+
+	send := frame(PulsarCommandSend, varint(1, 0), varint(2, 5), varint(3, 10))
+	send = append(send, 0x0e, 0x01, 0, 0, 0, 0) // magic number, checksum, metadata, payload
+	binary.BigEndian.PutUint32(send, binary.BigEndian.Uint32(send)+6)
+	assert.Nil(t, p.Parse(MethodPulsarClientFrames, send, 10*ms))
+	assert.Equal(t,
+		[]PulsarRequest{{Command: "SEND", Topic: orders, Messages: 10, Status: "ok", Duration: 4 * time.Millisecond, kernelTime: 10 * ms}},
+		p.Parse(MethodPulsarServerFrames, frame(PulsarCommandSendReceipt, varint(1, 0), varint(2, 5)), 14*ms))
+	assert.Nil(t, p.Parse(MethodPulsarServerFrames, frame(PulsarCommandSendReceipt, varint(1, 0), varint(2, 5)), 15*ms))
+
+	p = NewPulsarParser()
+	assert.Nil(t, p.Parse(MethodPulsarClientFrames, frame(PulsarCommandSubscribe, str(1, orders), str(2, "sub"), varint(4, 3), varint(5, 1)), 1*ms))
+	msg := frame(PulsarCommandMessage, varint(1, 3), str(2, "\x08\x01\x10\x02"))
+	assert.Equal(t,
+		[]PulsarRequest{{Command: "MESSAGE", Topic: orders, Messages: 1, Status: "ok"}},
+		p.Parse(MethodPulsarServerFrames, msg, 2*ms))
+	assert.Equal(t,
+		[]PulsarRequest{{Command: "ACK", Topic: orders, Messages: 2, Status: "ok"}},
+		p.Parse(MethodPulsarClientFrames, frame(PulsarCommandAck, varint(1, 3), varint(2, 0), str(3, "\x08\x01"), str(3, "\x08\x02")), 3*ms))
+
+	assert.Nil(t, p.Parse(MethodPulsarClientFrames, frame(PulsarCommandProducer, str(1, orders), varint(2, 4), varint(3, 2)), 4*ms))
+	assert.Len(t, p.producers, 1)
+	assert.Nil(t, p.Parse(MethodPulsarServerFrames, frame(PulsarCommandCloseProducer, varint(1, 4), varint(2, 3)), 5*ms))
+	assert.Empty(t, p.producers)
+	assert.Nil(t, p.Parse(MethodPulsarClientFrames, frame(PulsarCommandCloseConsumer, varint(1, 3), varint(2, 4)), 6*ms))
+	assert.Empty(t, p.consumers)
+	assert.Equal(t,
+		[]PulsarRequest{{Command: "MESSAGE", Topic: "", Messages: 1, Status: "ok"}},
+		p.Parse(MethodPulsarServerFrames, msg, 7*ms))
+
+	for i := 0; i < pulsarMaxTopicIds+10; i++ {
+		p.Parse(MethodPulsarClientFrames, frame(PulsarCommandProducer, str(1, orders), varint(2, uint64(i)), varint(3, 0)), 8*ms)
+	}
+	assert.Len(t, p.producers, pulsarMaxTopicIds)
+}
+
+func TestParseFastcgi(t *testing.T) {
+	record := func(typ byte, data []byte) []byte {
+		r := binary.BigEndian.AppendUint16([]byte{1, typ, 0, 1}, uint16(len(data)))
+		r = append(r, byte(len(data)%8), 0)
+		return append(append(r, data...), make([]byte, len(data)%8)...)
+	}
+	param := func(name, value string) []byte {
+		b := []byte{byte(len(name))}
+		if len(value) > 127 {
+			b = binary.BigEndian.AppendUint32(b, uint32(len(value))|0x80000000)
+		} else {
+			b = append(b, byte(len(value)))
+		}
+		return append(append(b, name...), value...)
+	}
+	params := bytes.Join([][]byte{
+		param("SCRIPT_FILENAME", "/var/www/html/index.php"),
+		param("QUERY_STRING", "page=2"),
+		param("REQUEST_METHOD", "GET"),
+		param("HTTP_COOKIE", strings.Repeat("x", 200)),
+		param("SCRIPT_NAME", "/index.php"),
+		param("REQUEST_URI", "/products/42?page=2"),
+	}, nil)
+	payload := append(record(1, []byte{0, 1, 1, 0, 0, 0, 0, 0}), record(4, params)...)
+	payload = append(payload, record(4, nil)...)
+	r := ParseFastcgi(payload)
+	assert.Equal(t, FastcgiRequest{Method: "GET", Uri: "/products/42?page=2", Script: "/index.php"}, r)
+	assert.Equal(t, "/products/42", r.Path())
+
+	r = ParseFastcgi(payload[:len(payload)-40])
+	assert.Equal(t, FastcgiRequest{Method: "GET", Uri: "/index.php", Script: "/index.php"}, r)
+
+	p := NewFastcgiParser()
+	p.ParseResponse(record(6, []byte("X-Powered-By: PHP/8.2.7\r\nStatus: 404 Not Found\r\nContent-type: text/html\r\n\r\n<html>")))
+	assert.Equal(t, Status(404), p.Status(StatusOk))
+	p.ParseResponse(record(6, []byte("Content-type: text/html\r\n\r\n<html>")))
+	assert.Equal(t, StatusOk, p.Status(StatusOk))
+	p.ParseResponse(record(3, []byte{0, 0, 0, 0, 0, 0, 0, 0}))
+	assert.Equal(t, StatusFailed, p.Status(StatusFailed))
+}
+
+func TestParseDns(t *testing.T) {
+	name := func(s string) dnsmessage.Name {
+		return dnsmessage.MustNewName(s)
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true})
+	b.EnableCompression()
+	assert.NoError(t, b.StartQuestions())
+	assert.NoError(t, b.Question(dnsmessage.Question{Name: name("www.example.com."), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}))
+	assert.NoError(t, b.StartAnswers())
+	hdr := func(n string, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name(n), Type: typ, Class: dnsmessage.ClassINET}
+	}
+	assert.NoError(t, b.CNAMEResource(hdr("www.example.com.", dnsmessage.TypeCNAME), dnsmessage.CNAMEResource{CNAME: name("www.example.com.cdn.net.")}))
+	assert.NoError(t, b.CNAMEResource(hdr("www.example.com.cdn.net.", dnsmessage.TypeCNAME), dnsmessage.CNAMEResource{CNAME: name("edge.cdn.net.")}))
+	assert.NoError(t, b.AResource(hdr("edge.cdn.net.", dnsmessage.TypeA), dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}}))
+	assert.NoError(t, b.AResource(hdr("edge.cdn.net.", dnsmessage.TypeA), dnsmessage.AResource{A: [4]byte{10, 0, 0, 2}}))
+	assert.NoError(t, b.AResource(hdr("other.cdn.net.", dnsmessage.TypeA), dnsmessage.AResource{A: [4]byte{10, 0, 0, 3}}))
+	payload, err := b.Finish()
+	assert.NoError(t, err)
+
+	r := ParseDns(payload)
+	assert.NotNil(t, r)
+	assert.Equal(t, "TypeA", r.Type)
+	assert.Equal(t, "www.example.com", r.Name)
+	assert.Equal(t, []string{"www.example.com.cdn.net", "edge.cdn.net"}, r.Cnames)
+	assert.Equal(t, []netaddr.IP{netaddr.MustParseIP("10.0.0.1"), netaddr.MustParseIP("10.0.0.2")}, r.IPs)
+
+	tcp := binary.BigEndian.AppendUint16(nil, uint16(len(payload)))
+	r = ParseDns(append(tcp, payload...))
+	assert.NotNil(t, r)
+	assert.Equal(t, "www.example.com", r.Name)
+	assert.Equal(t, []string{"www.example.com.cdn.net", "edge.cdn.net"}, r.Cnames)
+	assert.Len(t, r.IPs, 2)
+
+	assert.Nil(t, ParseDns([]byte{0, 1, 2}))
+}
+
+func TestParseElasticsearch(t *testing.T) {
+	assert.Equal(t, &ElasticsearchRequest{Operation: "search", Index: "logs-*"}, ParseElasticsearch("POST", "/logs-*/_search?size=10"))
+	assert.Equal(t, &ElasticsearchRequest{Operation: "search"}, ParseElasticsearch("GET", "/_search"))
+	assert.Equal(t, &ElasticsearchRequest{Operation: "msearch", Index: "orders"}, ParseElasticsearch("POST", "/orders/_msearch"))
+	assert.Equal(t, &ElasticsearchRequest{Operation: "bulk"}, ParseElasticsearch("POST", "/_bulk?refresh=wait_for"))
+	assert.Equal(t, &ElasticsearchRequest{Operation: "bulk", Index: "orders"}, ParseElasticsearch("PUT", "/orders/_bulk"))
+	assert.Equal(t, &ElasticsearchRequest{Operation: "index", Index: "orders"}, ParseElasticsearch("POST", "/orders/_doc"))
+	assert.Equal(t, &ElasticsearchRequest{Operation: "index", Index: "orders"}, ParseElasticsearch("PUT", "/orders/_doc/1"))
+	assert.Equal(t, &ElasticsearchRequest{Operation: "index", Index: "orders"}, ParseElasticsearch("PUT", "/orders/_create/1"))
+	assert.Equal(t, &ElasticsearchRequest{Operation: "get", Index: "orders"}, ParseElasticsearch("GET", "/orders/_doc/1"))
+	assert.Equal(t, &ElasticsearchRequest{Operation: "delete", Index: "orders"}, ParseElasticsearch("DELETE", "/orders/_doc/1"))
+	assert.Equal(t, &ElasticsearchRequest{Operation: "update", Index: "orders"}, ParseElasticsearch("POST", "/orders/_update/1"))
+
+	assert.Nil(t, ParseElasticsearch("GET", "/orders/_doc"))
+	assert.Nil(t, ParseElasticsearch("GET", "/_cluster/health"))
+	assert.Nil(t, ParseElasticsearch("GET", "/_cat/indices"))
+	assert.Nil(t, ParseElasticsearch("GET", "/api/v1/orders/1"))
+	assert.Nil(t, ParseElasticsearch("GET", "/"))
+	assert.Nil(t, ParseElasticsearch("", "/_search"))
+}
+
+func TestParseCloudRequest(t *testing.T) {
+	assert.Equal(t,
+		&CloudRequest{Provider: "aws", Service: "s3", Operation: "GetObject", Resource: "my.bucket"},
+		ParseCloudHttp([]byte("GET /path/to/key.json HTTP/1.1\r\nHost: my.bucket.s3.us-east-1.amazonaws.com\r\nX-Amz-Date: 20240101T000000Z\r\n\r\n")),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "aws", Service: "s3", Operation: "ListObjects", Resource: "bucket"},
+		ParseCloudHttp([]byte("GET /bucket?list-type=2&prefix=a HTTP/1.1\r\nHost: s3.amazonaws.com\r\n\r\n")),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "aws", Service: "s3", Operation: "UploadPart", Resource: "bucket"},
+		ParseCloudHttp([]byte("PUT /bucket/key?partNumber=1&uploadId=abc HTTP/1.1\r\nHost: s3-us-west-2.amazonaws.com:443\r\n")),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "aws", Service: "dynamodb", Operation: "GetItem", Resource: "orders"},
+		ParseCloudHttp([]byte("POST / HTTP/1.1\r\nHost: dynamodb.eu-west-1.amazonaws.com\r\nX-Amz-Target: DynamoDB_20120810.GetItem\r\nContent-Type: application/x-amz-json-1.0\r\n\r\n{\"TableName\": \"orders\", \"Key\": {\"id\": {\"S\": \"1\"}}}")),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "aws", Service: "sqs", Operation: "SendMessage", Resource: "jobs"},
+		ParseCloudHttp([]byte("POST / HTTP/1.1\r\nHost: sqs.us-east-1.amazonaws.com\r\nX-Amz-Target: AmazonSQS.SendMessage\r\n\r\n{\"QueueUrl\":\"https://sqs.us-east-1.amazonaws.com/123456789012/jobs\",\"MessageBody\":\"x\"}")),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "aws", Service: "sqs", Operation: "ReceiveMessage", Resource: "jobs"},
+		ParseCloudHttp([]byte("POST /123456789012/jobs HTTP/1.1\r\nHost: sqs.us-east-1.amazonaws.com\r\nContent-Type: application/x-www-form-urlencoded\r\n\r\nAction=ReceiveMessage&MaxNumberOfMessages=10")),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "aws", Service: "sts", Operation: "AssumeRoleWithWebIdentity"},
+		ParseCloudHttp([]byte("POST /?Action=AssumeRoleWithWebIdentity&Version=2011-06-15 HTTP/1.1\r\nHost: sts.amazonaws.com\r\n\r\n")),
+	)
+	assert.Nil(t, ParseCloudHttp([]byte("GET / HTTP/1.1\r\nHost: my-lb-123.us-east-1.elb.amazonaws.com\r\n\r\n")))
+	assert.Nil(t, ParseCloudHttp([]byte("GET / HTTP/1.1\r\nHost: ec2-1-2-3-4.compute-1.amazonaws.com\r\n\r\n")))
+	assert.Nil(t, ParseCloudHttp([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")))
+	assert.Nil(t, ParseCloudHttp([]byte("GET / HTTP/1.1\r\nHost: s3.amazon")))
+
+	assert.Equal(t,
+		&CloudRequest{Provider: "gcp", Service: "gcs", Operation: "objects.get", Resource: "bucket"},
+		ParseCloudRequest("GET", "storage.googleapis.com", "/storage/v1/b/bucket/o/a%2Fb.txt?alt=media", "", nil),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "gcp", Service: "gcs", Operation: "objects.insert", Resource: "bucket"},
+		ParseCloudRequest("POST", "storage.googleapis.com", "/upload/storage/v1/b/bucket/o?uploadType=multipart", "", nil),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "gcp", Service: "gcs", Operation: "objects.list", Resource: "bucket"},
+		ParseCloudRequest("GET", "storage.googleapis.com", "/storage/v1/b/bucket/o?prefix=a", "", nil),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "gcp", Service: "gcs", Operation: "PutObject", Resource: "bucket"},
+		ParseCloudRequest("PUT", "bucket.storage.googleapis.com", "/key", "", nil),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "gcp", Service: "pubsub", Operation: "publish"},
+		ParseCloudRequest("POST", "pubsub.googleapis.com", "/v1/projects/p/topics/t:publish", "", nil),
+	)
+
+	assert.Equal(t,
+		&CloudRequest{Provider: "azure", Service: "azure_blob", Operation: "GetBlob", Resource: "images"},
+		ParseCloudRequest("GET", "account.blob.core.windows.net", "/images/a/b.png", "", nil),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "azure", Service: "azure_blob", Operation: "ListBlobs", Resource: "images"},
+		ParseCloudRequest("GET", "account.blob.core.windows.net", "/images?restype=container&comp=list", "", nil),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "azure", Service: "azure_queue", Operation: "PutMessage", Resource: "jobs"},
+		ParseCloudRequest("POST", "account.queue.core.windows.net", "/jobs/messages", "", nil),
+	)
+	assert.Equal(t,
+		&CloudRequest{Provider: "azure", Service: "azure_table", Operation: "QueryEntities", Resource: "users"},
+		ParseCloudRequest("GET", "account.table.core.windows.net", "/users(PartitionKey='a',RowKey='b')", "", nil),
+	)
+}
+
+func TestParseGraphql(t *testing.T) {
+	post := func(body string) []byte {
+		return []byte("POST /graphql HTTP/1.1\r\nHost: api\r\nContent-Type: application/json\r\n\r\n" + body)
+	}
+	assert.Equal(t,
+		&GraphqlOperation{Type: "query", Name: "GetUser"},
+		ParseGraphql(post(`{"query":"query GetUser($id: ID!) { user(id: $id) { name } }","variables":{"id":"1"}}`)),
+	)
+	assert.Equal(t,
+		&GraphqlOperation{Type: "mutation", Name: "AddItem"},
+		ParseGraphql(post(`{"operationName":"AddItem","query":"# comment\nquery List { items { id } }\nmutation AddItem($input: ItemInput = {name: \"{\"}) { addItem(input: $input) { id } }"}`)),
+	)
+	assert.Equal(t,
+		&GraphqlOperation{Type: "subscription", Name: "OnEvent"},
+		ParseGraphql(post(`[{"query":"fragment F on Event { id }\nsubscription OnEvent { event { ...F } }"}]`)),
+	)
+	assert.Equal(t,
+		&GraphqlOperation{Type: "query"},
+		ParseGraphql(post(`{"query":"{ users { id } }"}`)),
+	)
+	assert.Equal(t,
+		&GraphqlOperation{Type: "query", Name: "Trunc"},
+		ParseGraphql(post(`{"query":"query Trunc { users(filter: {name: \"a`)),
+	)
+	assert.Equal(t,
+		&GraphqlOperation{Name: "Persisted"},
+		ParseGraphql(post(`{"operationName":"Persisted","extensions":{"persistedQuery":{"version":1,"sha256Hash":"abc"}}}`)),
+	)
+	assert.Equal(t,
+		&GraphqlOperation{Type: "query", Name: "Search"},
+		ParseGraphql([]byte("GET /graphql?query=query%20Search%20%7B%20a%20%7D&operationName=Search HTTP/1.1\r\nHost: api\r\n\r\n")),
+	)
+
+	assert.Nil(t, ParseGraphql(post(`{"query":"SELECT 1"}`)))
+	assert.Nil(t, ParseGraphql(post(`{"query":{"match_all":{}}}`)))
+	assert.Nil(t, ParseGraphql(post(`{"name":"query"}`)))
+	assert.Nil(t, ParseGraphql([]byte("GET /users?id=1 HTTP/1.1\r\nHost: api\r\n\r\n")))
+	assert.Nil(t, ParseGraphql([]byte("POST /graphql HTTP/1.1\r\nHost: api\r\n")))
+}
+
+func TestParseTraceContext(t *testing.T) {
+	tc := ParseHttpTraceContext([]byte("GET /api HTTP/1.1\r\nHost: api\r\nTraceparent: 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01\r\nTracestate: congo=t61rcWkgMzE\r\n\r\n"))
+	assert.NotNil(t, tc)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", hex.EncodeToString(tc.TraceId[:]))
+	assert.Equal(t, "b7ad6b7169203331", hex.EncodeToString(tc.SpanId[:]))
+	assert.True(t, tc.Sampled)
+	assert.Equal(t, "congo=t61rcWkgMzE", tc.TraceState)
+
+	tc = ParseHttpTraceContext([]byte("GET /api HTTP/1.1\r\ntraceparent: 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00\r\n"))
+	assert.NotNil(t, tc)
+	assert.False(t, tc.Sampled)
+
+	tc = ParseHttpTraceContext([]byte("GET /api HTTP/1.1\r\nb3: 80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90\r\n"))
+	assert.NotNil(t, tc)
+	assert.Equal(t, "80f198ee56343ba864fe8b2a57d3eff7", hex.EncodeToString(tc.TraceId[:]))
+	assert.Equal(t, "e457b5a2e4d86bd1", hex.EncodeToString(tc.SpanId[:]))
+	assert.True(t, tc.Sampled)
+
+	tc = ParseHttpTraceContext([]byte("GET /api HTTP/1.1\r\nX-B3-TraceId: 64fe8b2a57d3eff7\r\nX-B3-SpanId: e457b5a2e4d86bd1\r\nX-B3-Sampled: 0\r\n"))
+	assert.NotNil(t, tc)
+	assert.Equal(t, "000000000000000064fe8b2a57d3eff7", hex.EncodeToString(tc.TraceId[:]))
+	assert.False(t, tc.Sampled)
+
+	assert.Nil(t, ParseHttpTraceContext([]byte("GET /api HTTP/1.1\r\nb3: 0\r\n")))
+	assert.Nil(t, ParseHttpTraceContext([]byte("GET /api HTTP/1.1\r\ntraceparent: 00-00000000000000000000000000000000-b7ad6b7169203331-01\r\n")))
+	assert.Nil(t, ParseHttpTraceContext([]byte("GET /api HTTP/1.1\r\ntraceparent: 00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01\r\n")))
+	assert.Nil(t, ParseHttpTraceContext([]byte("GET /api HTTP/1.1\r\ntraceparent: 00-0af7651916cd43dd8448eb211c80319c-b7ad6b71")))
+	assert.Nil(t, ParseHttpTraceContext([]byte("GET /api HTTP/1.1\r\nHost: api\r\n\r\n")))
+
+	var h TraceHeaders
+	h.Set("traceparent", "01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-future")
+	h.Set("x-b3-traceid", "80f198ee56343ba864fe8b2a57d3eff7")
+	h.Set("x-b3-spanid", "e457b5a2e4d86bd1")
+	tc = h.TraceContext()
+	assert.NotNil(t, tc)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", hex.EncodeToString(tc.TraceId[:]))
+}
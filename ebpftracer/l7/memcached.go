@@ -2,15 +2,112 @@ package l7
 
 import (
 	"bytes"
+	"encoding/binary"
+	"strconv"
 	"strings"
 )
 
+// https://github.com/memcached/memcached/blob/master/doc/protocol.txt
+// https://github.com/memcached/memcached/wiki/BinaryProtocolRevamped
+
+const (
+	memcachedBinaryRequest    = 0x80
+	memcachedBinaryResponse   = 0x81
+	memcachedBinaryHeaderSize = 24
+	memcachedBinaryNoop       = 0x0a
+
+	memcachedMaxKeys = 100
+)
+
 var (
 	space = []byte{' '}
 	crlf  = []byte{'\r', '\n'}
 )
 
+var memcachedOpcodes = map[byte]string{
+	0x00: "get", 0x01: "set", 0x02: "add", 0x03: "replace", 0x04: "delete", 0x05: "incr", 0x06: "decr",
+	0x07: "quit", 0x08: "flush", 0x09: "getq", 0x0a: "noop", 0x0b: "version", 0x0c: "getk", 0x0d: "getkq",
+	0x0e: "append", 0x0f: "prepend", 0x10: "stat", 0x11: "setq", 0x12: "addq", 0x13: "replaceq", 0x14: "deleteq",
+	0x15: "incrq", 0x16: "decrq", 0x17: "quitq", 0x18: "flushq", 0x19: "appendq", 0x1a: "prependq",
+	0x1b: "verbosity", 0x1c: "touch", 0x1d: "gat", 0x1e: "gatq", 0x20: "sasl_list_mechs", 0x21: "sasl_auth",
+	0x22: "sasl_step", 0x23: "gatk", 0x24: "gatkq",
+}
+
+// binary protocol response statuses reported in the status label
+var memcachedBinaryStatuses = map[uint16]string{
+	0x03: "value_too_large",
+	0x04: "invalid_arguments",
+	0x06: "non_numeric_value",
+	0x07: "wrong_vbucket",
+	0x08: "auth_error",
+	0x81: "unknown_command",
+	0x82: "out_of_memory",
+	0x83: "not_supported",
+	0x84: "internal_error",
+	0x85: "busy",
+	0x86: "temporary_failure",
+}
+
+// text protocol errors reported in the status label
+var memcachedTextErrors = map[string]string{
+	"ERROR":        "error",
+	"CLIENT_ERROR": "client_error",
+	"SERVER_ERROR": "server_error",
+}
+
+type MemcachedParser struct {
+	lastError string
+	lastMsg   string
+}
+
+func NewMemcachedParser() *MemcachedParser {
+	return &MemcachedParser{}
+}
+
+// ParseErrorResponse parses a text error (SERVER_ERROR out of memory storing object) or the status of a binary response
+// that precedes the failed request
+func (p *MemcachedParser) ParseErrorResponse(payload []byte) {
+	p.lastError, p.lastMsg = "", ""
+	if len(payload) >= memcachedBinaryHeaderSize && payload[0] == memcachedBinaryResponse {
+		status := binary.BigEndian.Uint16(payload[6:])
+		if s, ok := memcachedBinaryStatuses[status]; ok {
+			p.lastError = s
+		} else {
+			p.lastError = "status:" + strconv.Itoa(int(status))
+		}
+		extras, key := int(payload[4]), int(binary.BigEndian.Uint16(payload[2:]))
+		if body := payload[memcachedBinaryHeaderSize:]; len(body) > extras+key {
+			p.lastMsg = string(body[extras+key:])
+		}
+		return
+	}
+	line, _, _ := bytes.Cut(payload, crlf)
+	prefix, msg, _ := bytes.Cut(line, space)
+	if s, ok := memcachedTextErrors[string(prefix)]; ok {
+		p.lastError, p.lastMsg = s, string(msg)
+	}
+}
+
+// Status returns the status of the request: ok, the error reported by the server (e.g., server_error, out_of_memory),
+// or failed, and the error message if any
+func (p *MemcachedParser) Status(status Status) (string, string) {
+	if !status.Error() {
+		return status.String(), ""
+	}
+	label, msg := p.lastError, p.lastMsg
+	p.lastError, p.lastMsg = "", ""
+	if label == "" {
+		return status.String(), msg
+	}
+	return label, msg
+}
+
+// ParseMemcached returns the command and the keys of a text, meta or binary protocol request.
+// The keys of the pipelined commands are collected until the end of the (usually truncated) payload.
 func ParseMemcached(payload []byte) (string, []string) {
+	if len(payload) > 0 && payload[0] == memcachedBinaryRequest {
+		return parseMemcachedBinary(payload)
+	}
 	cmd, rest, ok := bytes.Cut(payload, space)
 	if !ok {
 		return "", nil
@@ -34,6 +131,59 @@ func ParseMemcached(payload []byte) (string, []string) {
 		if ok {
 			return command, strings.Split(string(keys), " ")
 		}
+	case "mg", "ms", "md", "ma":
+		return command, parseMemcachedMeta(payload)
 	}
 	return "", nil
 }
+
+// parseMemcachedMeta reads pipelined meta commands: <cmd> <key> <flags>*\r\n, ms is followed by the data block
+func parseMemcachedMeta(payload []byte) []string {
+	var keys []string
+	for len(payload) > 0 && len(keys) < memcachedMaxKeys {
+		line, rest, ok := bytes.Cut(payload, crlf)
+		if !ok {
+			break
+		}
+		fields := bytes.Fields(line)
+		if len(fields) < 2 {
+			payload = rest
+			continue // mn
+		}
+		keys = append(keys, string(fields[1]))
+		if string(fields[0]) == "ms" && len(fields) > 2 {
+			size, err := strconv.Atoi(string(fields[2]))
+			if err != nil || len(rest) < size+2 {
+				break
+			}
+			rest = rest[size+2:]
+		}
+		payload = rest
+	}
+	return keys
+}
+
+func parseMemcachedBinary(payload []byte) (string, []string) {
+	var command string
+	var keys []string
+	for len(payload) >= memcachedBinaryHeaderSize && payload[0] == memcachedBinaryRequest && len(keys) < memcachedMaxKeys {
+		opcode := payload[1]
+		keyLen := int(binary.BigEndian.Uint16(payload[2:]))
+		extrasLen := int(payload[4])
+		bodyLen := int(binary.BigEndian.Uint32(payload[8:]))
+		if command == "" && opcode != memcachedBinaryNoop {
+			if command = memcachedOpcodes[opcode]; command == "" {
+				command = "opcode:" + strconv.Itoa(int(opcode))
+			}
+		}
+		body := payload[memcachedBinaryHeaderSize:]
+		if keyLen > 0 && len(body) >= extrasLen+keyLen {
+			keys = append(keys, string(body[extrasLen:extrasLen+keyLen]))
+		}
+		if len(body) < bodyLen {
+			break
+		}
+		payload = body[bodyLen:]
+	}
+	return command, keys
+}
@@ -1,7 +1,9 @@
 package l7
 
 import (
+	"bytes"
 	"encoding/binary"
+	"math"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
@@ -36,3 +38,211 @@ func ParseMongo(payload []byte) (res string) {
 	}
 	return bson.Raw(sectionData).String()
 }
+
+const (
+	mongoBsonDouble   = 0x01
+	mongoBsonString   = 0x02
+	mongoBsonDocument = 0x03
+	mongoBsonArray    = 0x04
+	mongoBsonBinary   = 0x05
+	mongoBsonObjectId = 0x07
+	mongoBsonBool     = 0x08
+	mongoBsonDatetime = 0x09
+	mongoBsonNull     = 0x0A
+	mongoBsonRegex    = 0x0B
+	mongoBsonInt32    = 0x10
+	mongoBsonTime     = 0x11
+	mongoBsonInt64    = 0x12
+	mongoBsonDecimal  = 0x13
+)
+
+type MongoCommand struct {
+	Name       string
+	Db         string
+	Collection string
+}
+
+type MongoReply struct {
+	Ok       bool
+	Code     int
+	CodeName string
+	ErrMsg   string
+}
+
+type MongoParser struct {
+	lastReply *MongoReply
+}
+
+func NewMongoParser() *MongoParser {
+	return &MongoParser{}
+}
+
+// ParseReply parses the OP_MSG error reply that precedes the failed request
+func (p *MongoParser) ParseReply(payload []byte) {
+	p.lastReply = ParseMongoReply(payload)
+}
+
+// Reply returns the error reply to the last request or nil if there is none
+func (p *MongoParser) Reply() *MongoReply {
+	r := p.lastReply
+	p.lastReply = nil
+	return r
+}
+
+// ParseMongoCommand extracts the command name (the first field of the body), the database ($db),
+// and the collection (the value of the first field or `collection` for getMore) from an OP_MSG request.
+// Since the payload is usually truncated, some of the fields can be empty.
+func ParseMongoCommand(payload []byte) *MongoCommand {
+	body := mongoMsgBody(payload)
+	if body == nil {
+		return nil
+	}
+	cmd := &MongoCommand{}
+	mongoBsonElements(body, func(key string, typ byte, value []byte) bool {
+		switch {
+		case cmd.Name == "":
+			cmd.Name = key
+			if typ == mongoBsonString {
+				cmd.Collection = mongoBsonReadString(value)
+			}
+		case key == "$db" && typ == mongoBsonString:
+			cmd.Db = mongoBsonReadString(value)
+		case key == "collection" && typ == mongoBsonString && cmd.Name == "getMore":
+			cmd.Collection = mongoBsonReadString(value)
+		}
+		return cmd.Db == "" || cmd.Collection == ""
+	})
+	if cmd.Name == "" {
+		return nil
+	}
+	return cmd
+}
+
+// ParseMongoReply parses the `ok`, `code`, `codeName`, and `errmsg` fields of an OP_MSG reply.
+// The payload may start without the message length if the reply was read in two parts.
+func ParseMongoReply(payload []byte) *MongoReply {
+	body := mongoMsgBody(payload)
+	if body == nil && len(payload) >= mongoHeaderLength-4 && binary.LittleEndian.Uint32(payload[mongoOpCodeOffset-4:]) == MongoOpMSG {
+		body = mongoMsgBody(append(make([]byte, 4), payload...))
+	}
+	if body == nil {
+		return nil
+	}
+	r := &MongoReply{}
+	found := false
+	mongoBsonElements(body, func(key string, typ byte, value []byte) bool {
+		switch key {
+		case "ok":
+			found = true
+			switch typ {
+			case mongoBsonDouble:
+				r.Ok = math.Float64frombits(binary.LittleEndian.Uint64(value)) == 1
+			case mongoBsonInt32:
+				r.Ok = binary.LittleEndian.Uint32(value) == 1
+			case mongoBsonInt64:
+				r.Ok = binary.LittleEndian.Uint64(value) == 1
+			case mongoBsonBool:
+				r.Ok = value[0] == 1
+			}
+		case "code":
+			if typ == mongoBsonInt32 {
+				r.Code = int(int32(binary.LittleEndian.Uint32(value)))
+			}
+		case "codeName":
+			r.CodeName = mongoBsonReadString(value)
+		case "errmsg":
+			r.ErrMsg = mongoBsonReadString(value)
+		}
+		return true
+	})
+	if !found {
+		return nil
+	}
+	return r
+}
+
+func mongoMsgBody(payload []byte) []byte {
+	if len(payload) < mongoHeaderLength+mongoSectionKindLength+mongoSectionSizeLength {
+		return nil
+	}
+	if binary.LittleEndian.Uint32(payload[mongoOpCodeOffset:]) != MongoOpMSG {
+		return nil
+	}
+	if payload[mongoHeaderLength] != mongoSectionKindBody {
+		return nil
+	}
+	return payload[mongoHeaderLength+mongoSectionKindLength:]
+}
+
+// mongoBsonElements iterates over the elements of a (possibly truncated) BSON document until f returns false
+func mongoBsonElements(doc []byte, f func(key string, typ byte, value []byte) bool) {
+	if len(doc) < 4 {
+		return
+	}
+	doc = doc[4:]
+	for len(doc) > 1 {
+		typ := doc[0]
+		key, rest, ok := bytes.Cut(doc[1:], []byte{0})
+		if !ok {
+			return
+		}
+		size := mongoBsonValueSize(typ, rest)
+		if size < 0 || size > len(rest) {
+			return
+		}
+		if !f(string(key), typ, rest[:size]) {
+			return
+		}
+		doc = rest[size:]
+	}
+}
+
+func mongoBsonValueSize(typ byte, value []byte) int {
+	switch typ {
+	case mongoBsonDouble, mongoBsonDatetime, mongoBsonTime, mongoBsonInt64:
+		return 8
+	case mongoBsonString:
+		if len(value) < 4 {
+			return -1
+		}
+		return 4 + int(int32(binary.LittleEndian.Uint32(value)))
+	case mongoBsonDocument, mongoBsonArray:
+		if len(value) < 4 {
+			return -1
+		}
+		return int(int32(binary.LittleEndian.Uint32(value)))
+	case mongoBsonBinary:
+		if len(value) < 4 {
+			return -1
+		}
+		return 4 + 1 + int(int32(binary.LittleEndian.Uint32(value)))
+	case mongoBsonObjectId:
+		return 12
+	case mongoBsonBool:
+		return 1
+	case mongoBsonNull:
+		return 0
+	case mongoBsonRegex:
+		pattern := bytes.IndexByte(value, 0)
+		if pattern < 0 {
+			return -1
+		}
+		options := bytes.IndexByte(value[pattern+1:], 0)
+		if options < 0 {
+			return -1
+		}
+		return pattern + options + 2
+	case mongoBsonInt32:
+		return 4
+	case mongoBsonDecimal:
+		return 16
+	}
+	return -1
+}
+
+func mongoBsonReadString(value []byte) string {
+	if len(value) < 5 {
+		return ""
+	}
+	return string(value[4 : len(value)-1])
+}
@@ -0,0 +1,304 @@
+package l7
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// https://docs.oasis-open.org/mqtt/mqtt/v3.1.1/mqtt-v3.1.1.html
+// https://docs.oasis-open.org/mqtt/mqtt/v5.0/mqtt-v5.0.html
+
+const (
+	MqttPacketConnect     = 1
+	MqttPacketConnack     = 2
+	MqttPacketPublish     = 3
+	MqttPacketPuback      = 4
+	MqttPacketPubrec      = 5
+	MqttPacketSubscribe   = 8
+	MqttPacketSuback      = 9
+	MqttPacketUnsubscribe = 10
+	MqttPacketUnsuback    = 11
+
+	mqttVersion5          = 5
+	mqttPendingGcInterval = uint64(10 * time.Minute)
+)
+
+var mqttPacketNames = map[byte]string{
+	MqttPacketConnect:     "CONNECT",
+	MqttPacketPublish:     "PUBLISH",
+	MqttPacketSubscribe:   "SUBSCRIBE",
+	MqttPacketUnsubscribe: "UNSUBSCRIBE",
+}
+
+// MQTT 5 reason codes indicating a failure
+var mqttReasonCodes = map[byte]string{
+	0x80: "unspecified_error",
+	0x81: "malformed_packet",
+	0x82: "protocol_error",
+	0x83: "implementation_specific_error",
+	0x84: "unsupported_protocol_version",
+	0x85: "client_identifier_not_valid",
+	0x86: "bad_username_or_password",
+	0x87: "not_authorized",
+	0x88: "server_unavailable",
+	0x89: "server_busy",
+	0x8A: "banned",
+	0x8C: "bad_authentication_method",
+	0x8F: "topic_filter_invalid",
+	0x90: "topic_name_invalid",
+	0x91: "packet_identifier_in_use",
+	0x95: "packet_too_large",
+	0x97: "quota_exceeded",
+	0x99: "payload_format_invalid",
+	0x9A: "retain_not_supported",
+	0x9B: "qos_not_supported",
+	0x9C: "use_another_server",
+	0x9D: "server_moved",
+	0x9E: "shared_subscriptions_not_supported",
+	0x9F: "connection_rate_exceeded",
+	0xA1: "subscription_identifiers_not_supported",
+	0xA2: "wildcard_subscriptions_not_supported",
+}
+
+// MQTT 3.1.1 CONNACK return codes
+var mqttConnackReturnCodes = map[byte]string{
+	1: "unsupported_protocol_version",
+	2: "client_identifier_not_valid",
+	3: "server_unavailable",
+	4: "bad_username_or_password",
+	5: "not_authorized",
+}
+
+type MqttRequest struct {
+	Packet   string // CONNECT, PUBLISH, SUBSCRIBE or UNSUBSCRIBE
+	Method   Method // MethodProduce or MethodConsume for PUBLISH
+	Topics   []string
+	Qos      int
+	Status   string // ok, no_matching_subscribers, or the reason of the failure reported by the broker
+	Failed   bool
+	Duration time.Duration // the time until the acknowledgment: CONNACK, PUBACK/PUBREC (QoS > 0), SUBACK or UNSUBACK
+
+	kernelTime uint64
+}
+
+type MqttParser struct {
+	version    byte // the protocol level sent in CONNECT: 3 (3.1), 4 (3.1.1) or 5
+	connect    *MqttRequest
+	pending    map[uint16]*MqttRequest // packet identifier -> PUBLISH (QoS > 0), SUBSCRIBE or UNSUBSCRIBE
+	lastGcTime uint64
+}
+
+func NewMqttParser() *MqttParser {
+	return &MqttParser{pending: map[uint16]*MqttRequest{}}
+}
+
+// Parse parses the control packets sent (MethodMqttClientPackets) or received (MethodMqttServerPackets) by the client.
+// It returns the published and received messages and the acknowledged requests.
+func (p *MqttParser) Parse(method Method, payload []byte, kernelTime uint64) []MqttRequest {
+	var res []MqttRequest
+	for len(payload) > 1 {
+		typ, flags := payload[0]>>4, payload[0]&0x0F
+		length, n := mqttReadVarInt(payload[1:])
+		if n == 0 {
+			break
+		}
+		data := payload[1+n:]
+		if len(data) > length {
+			data = data[:length]
+		}
+		switch method {
+		case MethodMqttClientPackets:
+			if r := p.parseClientPacket(typ, flags, data, kernelTime); r != nil {
+				res = append(res, *r)
+			}
+		case MethodMqttServerPackets:
+			if r := p.parseServerPacket(typ, flags, data, kernelTime); r != nil {
+				res = append(res, *r)
+			}
+		}
+		if 1+n+length > len(payload) { // truncated
+			break
+		}
+		payload = payload[1+n+length:]
+	}
+	p.gc(kernelTime)
+	return res
+}
+
+func (p *MqttParser) parseClientPacket(typ, flags byte, data []byte, kernelTime uint64) *MqttRequest {
+	r := &MqttRequest{Packet: mqttPacketNames[typ], kernelTime: kernelTime}
+	switch typ {
+	case MqttPacketConnect:
+		if len(data) < 2 {
+			return nil
+		}
+		nameLen := int(binary.BigEndian.Uint16(data))
+		if len(data) > 2+nameLen {
+			p.version = data[2+nameLen]
+		}
+		p.connect = r
+		return nil
+	case MqttPacketPublish:
+		r.Method = MethodProduce
+		r.Qos = int(flags>>1) & 0x03
+		topic, data, ok := mqttReadString(data)
+		if !ok {
+			return nil
+		}
+		r.Topics = []string{topic}
+		if r.Qos == 0 {
+			r.Status = "ok"
+			return r
+		}
+		if len(data) < 2 {
+			return nil
+		}
+		p.pending[binary.BigEndian.Uint16(data)] = r
+		return nil
+	case MqttPacketSubscribe, MqttPacketUnsubscribe:
+		if len(data) < 2 {
+			return nil
+		}
+		id := binary.BigEndian.Uint16(data)
+		data = p.skipProperties(data[2:])
+		for len(data) > 0 {
+			topic, rest, ok := mqttReadString(data)
+			if !ok {
+				break
+			}
+			r.Topics = append(r.Topics, topic)
+			data = rest
+			if typ == MqttPacketSubscribe && len(data) > 0 {
+				data = data[1:] // subscription options
+			}
+		}
+		p.pending[id] = r
+	}
+	return nil
+}
+
+func (p *MqttParser) parseServerPacket(typ, flags byte, data []byte, kernelTime uint64) *MqttRequest {
+	switch typ {
+	case MqttPacketPublish:
+		topic, _, ok := mqttReadString(data)
+		if !ok {
+			return nil
+		}
+		return &MqttRequest{
+			Packet: mqttPacketNames[typ],
+			Method: MethodConsume,
+			Topics: []string{topic},
+			Qos:    int(flags>>1) & 0x03,
+			Status: "ok",
+		}
+	case MqttPacketConnack:
+		r := p.connect
+		p.connect = nil
+		if r == nil || len(data) < 2 {
+			return nil
+		}
+		r.Status, r.Failed = p.status(data[1], true)
+		return r.ack(kernelTime)
+	case MqttPacketPuback, MqttPacketPubrec, MqttPacketSuback, MqttPacketUnsuback:
+		if len(data) < 2 {
+			return nil
+		}
+		id := binary.BigEndian.Uint16(data)
+		r := p.pending[id]
+		if r == nil {
+			return nil
+		}
+		delete(p.pending, id)
+		data = data[2:]
+		var code byte
+		switch typ {
+		case MqttPacketPuback, MqttPacketPubrec:
+			if len(data) > 0 { // MQTT 5: the reason code is omitted on success
+				code = data[0]
+			}
+		case MqttPacketSuback, MqttPacketUnsuback:
+			// one reason code per topic filter (UNSUBACK has none before MQTT 5): reporting the first failure if any
+			for _, c := range p.skipProperties(data) {
+				if c >= 0x80 {
+					code = c
+					break
+				}
+			}
+		}
+		r.Status, r.Failed = p.status(code, false)
+		return r.ack(kernelTime)
+	}
+	return nil
+}
+
+func (r *MqttRequest) ack(kernelTime uint64) *MqttRequest {
+	if kernelTime > r.kernelTime {
+		r.Duration = time.Duration(kernelTime - r.kernelTime)
+	}
+	return r
+}
+
+func (p *MqttParser) status(code byte, connack bool) (string, bool) {
+	switch {
+	case code == 0x10:
+		return "no_matching_subscribers", false
+	case code < 0x80 && connack && p.version != mqttVersion5 && code > 0:
+		if s, ok := mqttConnackReturnCodes[code]; ok {
+			return s, true
+		}
+		return "failed", true
+	case code < 0x80:
+		return "ok", false
+	}
+	if s, ok := mqttReasonCodes[code]; ok && p.version == mqttVersion5 {
+		return s, true
+	}
+	return "failed", true
+}
+
+// skipProperties skips the properties of the variable header (MQTT 5 only)
+func (p *MqttParser) skipProperties(data []byte) []byte {
+	if p.version != mqttVersion5 {
+		return data
+	}
+	l, n := mqttReadVarInt(data)
+	if n == 0 || n+l > len(data) {
+		return nil
+	}
+	return data[n+l:]
+}
+
+// gc removes the requests that have never been acknowledged, e.g., because of the lost events
+func (p *MqttParser) gc(kernelTime uint64) {
+	if kernelTime-p.lastGcTime < mqttPendingGcInterval {
+		return
+	}
+	for id, r := range p.pending {
+		if kernelTime-r.kernelTime > mqttPendingGcInterval {
+			delete(p.pending, id)
+		}
+	}
+	p.lastGcTime = kernelTime
+}
+
+func mqttReadVarInt(data []byte) (int, int) {
+	v := 0
+	for i := 0; i < 4 && i < len(data); i++ {
+		v |= int(data[i]&0x7F) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+func mqttReadString(data []byte) (string, []byte, bool) {
+	if len(data) < 2 {
+		return "", nil, false
+	}
+	l := int(binary.BigEndian.Uint16(data))
+	if l == 0 || len(data) < 2+l {
+		return "", nil, false
+	}
+	return string(data[2 : 2+l]), data[2+l:], true
+}
@@ -0,0 +1,286 @@
+package l7
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/b46a581a-39de-4745-b076-ec4dbb7d13ec
+
+const (
+	MssqlPacketSqlBatch = 0x01
+	MssqlPacketRpc      = 0x03
+	MssqlPacketResponse = 0x04
+
+	mssqlHeaderLength = 8
+
+	mssqlTokenReturnStatus = 0x79
+	mssqlTokenError        = 0xAA
+	mssqlTokenInfo         = 0xAB
+	mssqlTokenEnvChange    = 0xE3
+	mssqlTokenDone         = 0xFD
+	mssqlTokenDoneProc     = 0xFE
+	mssqlTokenDoneInProc   = 0xFF
+
+	mssqlTypeIntN       = 0x26
+	mssqlTypeBigVarChar = 0xA7
+	mssqlTypeNVarChar   = 0xE7
+)
+
+// well-known stored procedures called by id
+var mssqlProcIds = map[uint16]string{
+	1:  "sp_cursor",
+	2:  "sp_cursoropen",
+	3:  "sp_cursorprepare",
+	4:  "sp_cursorexecute",
+	5:  "sp_cursorprepexec",
+	6:  "sp_cursorunprepare",
+	7:  "sp_cursorfetch",
+	8:  "sp_cursoroption",
+	9:  "sp_cursorclose",
+	10: "sp_executesql",
+	11: "sp_prepare",
+	12: "sp_execute",
+	13: "sp_prepexec",
+	14: "sp_prepexecrpc",
+	15: "sp_unprepare",
+}
+
+type MssqlError struct {
+	Number  int
+	Class   int // severity
+	Message string
+}
+
+type MssqlParser struct {
+	lastError *MssqlError
+}
+
+func NewMssqlParser() *MssqlParser {
+	return &MssqlParser{}
+}
+
+// ParseErrorResponse parses the first ERROR token of the response that precedes the failed request
+func (p *MssqlParser) ParseErrorResponse(payload []byte) {
+	p.lastError = ParseMssqlError(payload)
+}
+
+// Error returns the error of the last failed request
+func (p *MssqlParser) Error() *MssqlError {
+	e := p.lastError
+	p.lastError = nil
+	return e
+}
+
+// ParseMssql returns the statement of an SQL batch or the RPC call, e.g., `EXEC sp_executesql <statement>`
+// is reported as the statement itself, calls of other procedures as `EXEC <procedure>`.
+func ParseMssql(payload []byte) string {
+	if len(payload) <= mssqlHeaderLength {
+		return ""
+	}
+	typ := payload[0]
+	truncated := int(binary.BigEndian.Uint16(payload[2:])) > len(payload)
+	data := mssqlSkipAllHeaders(payload[mssqlHeaderLength:])
+	if data == nil {
+		return ""
+	}
+	switch typ {
+	case MssqlPacketSqlBatch:
+		query := mssqlDecodeUcs2(data)
+		if truncated {
+			query += "..."
+		}
+		return query
+	case MssqlPacketRpc:
+		return mssqlParseRpc(data)
+	}
+	return ""
+}
+
+// ALL_HEADERS is present since TDS 7.2: the total length followed by the headers
+func mssqlSkipAllHeaders(data []byte) []byte {
+	if len(data) < 4 {
+		return nil
+	}
+	l := int(binary.LittleEndian.Uint32(data))
+	if l < 4 || l > len(data) { // no headers (TDS < 7.2)
+		return data
+	}
+	return data[l:]
+}
+
+func mssqlParseRpc(data []byte) string {
+	if len(data) < 2 {
+		return ""
+	}
+	var proc string
+	nameLen := binary.LittleEndian.Uint16(data)
+	data = data[2:]
+	if nameLen == 0xFFFF {
+		if len(data) < 2 {
+			return ""
+		}
+		id := binary.LittleEndian.Uint16(data)
+		data = data[2:]
+		var ok bool
+		if proc, ok = mssqlProcIds[id]; !ok {
+			return fmt.Sprintf("EXEC %d", id)
+		}
+	} else {
+		if len(data) < int(nameLen)*2 {
+			return "EXEC " + mssqlDecodeUcs2(data)
+		}
+		proc = mssqlDecodeUcs2(data[:nameLen*2])
+		data = data[nameLen*2:]
+	}
+	if len(data) < 2 {
+		return "EXEC " + proc
+	}
+	data = data[2:] // option flags
+
+	stmtParam := -1
+	switch proc {
+	case "sp_executesql":
+		stmtParam = 0 // @stmt, @params, ...
+	case "sp_prepare", "sp_prepexec", "sp_cursorprepare", "sp_cursorprepexec":
+		stmtParam = 2 // @handle OUTPUT, @params, @stmt, ...
+	case "sp_cursoropen":
+		stmtParam = 1 // @cursor OUTPUT, @stmt, ...
+	}
+	for i := 0; i <= stmtParam; i++ {
+		typ, value, rest, ok := mssqlReadParam(data)
+		if i == stmtParam && value != nil {
+			var stmt string
+			if typ == mssqlTypeBigVarChar {
+				stmt = string(value)
+			} else {
+				stmt = mssqlDecodeUcs2(value)
+			}
+			if !ok {
+				stmt += "..."
+			}
+			return stmt
+		}
+		if !ok {
+			break
+		}
+		data = rest
+	}
+	return "EXEC " + proc
+}
+
+// mssqlReadParam reads a parameter of the INTN, NVARCHAR or VARCHAR types and returns its type and value.
+// The truncated value of the last parameter in the payload is returned with ok=false.
+func mssqlReadParam(data []byte) (typ byte, value []byte, rest []byte, ok bool) {
+	if len(data) < 1 {
+		return
+	}
+	nameLen := int(data[0]) * 2
+	if len(data) < 1+nameLen+2 {
+		return
+	}
+	data = data[1+nameLen+1:] // name, status flags
+	typ = data[0]
+	data = data[1:]
+	switch typ {
+	case mssqlTypeIntN:
+		if len(data) < 2 {
+			return
+		}
+		l := int(data[1])
+		data = data[2:]
+		if len(data) < l {
+			return
+		}
+		return typ, data[:l], data[l:], true
+	case mssqlTypeNVarChar, mssqlTypeBigVarChar:
+		if len(data) < 2+5+2 { // max length, collation, length
+			return
+		}
+		maxLen := binary.LittleEndian.Uint16(data)
+		data = data[2+5:]
+		if maxLen == 0xFFFF { // PLP: total length, then chunks
+			if len(data) < 8+4 {
+				return
+			}
+			data = data[8:]
+			l := int(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+			if len(data) < l {
+				return typ, data, nil, false
+			}
+			return typ, data[:l], data[l:], true
+		}
+		l := int(binary.LittleEndian.Uint16(data))
+		data = data[2:]
+		if l == 0xFFFF { // NULL
+			return typ, nil, data, true
+		}
+		if len(data) < l {
+			return typ, data, nil, false
+		}
+		return typ, data[:l], data[l:], true
+	}
+	return
+}
+
+// ParseMssqlError parses the first ERROR token of the response
+func ParseMssqlError(payload []byte) *MssqlError {
+	if len(payload) <= mssqlHeaderLength || payload[0] != MssqlPacketResponse {
+		return nil
+	}
+	data := payload[mssqlHeaderLength:]
+	for len(data) > 0 {
+		token := data[0]
+		data = data[1:]
+		switch token {
+		case mssqlTokenError:
+			// length, number, state, class, message, server name, procedure name, line number
+			if len(data) < 2+4+1+1+2 {
+				return nil
+			}
+			e := &MssqlError{
+				Number: int(int32(binary.LittleEndian.Uint32(data[2:]))),
+				Class:  int(data[7]),
+			}
+			l := int(binary.LittleEndian.Uint16(data[8:])) * 2
+			msg := data[10:]
+			if len(msg) > l {
+				msg = msg[:l]
+			}
+			e.Message = mssqlDecodeUcs2(msg)
+			return e
+		case mssqlTokenInfo, mssqlTokenEnvChange:
+			if len(data) < 2 {
+				return nil
+			}
+			l := 2 + int(binary.LittleEndian.Uint16(data))
+			if len(data) < l {
+				return nil
+			}
+			data = data[l:]
+		case mssqlTokenReturnStatus:
+			if len(data) < 4 {
+				return nil
+			}
+			data = data[4:]
+		case mssqlTokenDone, mssqlTokenDoneProc, mssqlTokenDoneInProc:
+			if len(data) < 12 { // status, current command, row count (TDS 7.2+)
+				return nil
+			}
+			data = data[12:]
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func mssqlDecodeUcs2(data []byte) string {
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
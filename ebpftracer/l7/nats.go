@@ -0,0 +1,21 @@
+package l7
+
+import (
+	"bytes"
+)
+
+// https://docs.nats.io/reference/reference-protocols/nats-protocol
+
+// ParseNats returns the subject of a PUB, HPUB, MSG or HMSG message
+func ParseNats(payload []byte) string {
+	line, _, _ := bytes.Cut(payload, []byte("\r\n"))
+	fields := bytes.Fields(line)
+	if len(fields) < 3 {
+		return ""
+	}
+	switch string(fields[0]) {
+	case "PUB", "HPUB", "MSG", "HMSG":
+		return string(fields[1])
+	}
+	return ""
+}
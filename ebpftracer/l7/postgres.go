@@ -2,7 +2,10 @@ package l7
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"strings"
+	"time"
 )
 
 const (
@@ -10,10 +13,34 @@ const (
 	PostgresFrameBind  byte = 'B'
 	PostgresFrameParse byte = 'P'
 	PostgresFrameClose byte = 'C'
+
+	PostgresFrameErrorResponse byte = 'E'
+
+	PostgresTransactionCommit   = "commit"
+	PostgresTransactionRollback = "rollback"
 )
 
+type PostgresError struct {
+	Severity string
+	Code     string // SQLSTATE
+	Message  string
+}
+
+// Class returns the SQLSTATE class, e.g., 40 for deadlock_detected (40P01) and serialization_failure (40001)
+func (e *PostgresError) Class() string {
+	if e == nil || len(e.Code) < 2 {
+		return ""
+	}
+	return e.Code[:2]
+}
+
 type PostgresParser struct {
 	preparedStatements map[string]string
+
+	lastError          *PostgresError
+	inTransaction      bool
+	transactionAborted bool // a statement has failed since BEGIN, so COMMIT will roll the transaction back
+	lastStatementEnd   time.Time
 }
 
 func NewPostgresParser() *PostgresParser {
@@ -80,3 +107,100 @@ func (p *PostgresParser) Parse(payload []byte) string {
 	}
 	return ""
 }
+
+// ParseErrorResponse parses an ErrorResponse message that precedes the failed request
+func (p *PostgresParser) ParseErrorResponse(payload []byte) {
+	p.lastError = nil
+	for len(payload) >= 5 {
+		cmd := payload[0]
+		l := int(binary.BigEndian.Uint32(payload[1:]))
+		if cmd != PostgresFrameErrorResponse {
+			if l < 4 || len(payload) < 1+l {
+				return
+			}
+			payload = payload[1+l:]
+			continue
+		}
+		e := &PostgresError{}
+		fields := payload[5:]
+		for len(fields) > 1 && fields[0] != 0 {
+			t := fields[0]
+			v, rest, _ := bytes.Cut(fields[1:], []byte{0})
+			switch t {
+			case 'S':
+				if e.Severity == "" {
+					e.Severity = string(v)
+				}
+			case 'V': // non-localized severity
+				e.Severity = string(v)
+			case 'C':
+				e.Code = string(v)
+			case 'M':
+				e.Message = string(v)
+			}
+			fields = rest
+		}
+		p.lastError = e
+		return
+	}
+}
+
+// Error returns the error of the last failed request
+func (p *PostgresParser) Error() *PostgresError {
+	e := p.lastError
+	p.lastError = nil
+	return e
+}
+
+// Transaction tracks transaction boundaries on the connection. It returns the transaction outcome
+// if the statement completes a transaction, and the time the connection was idle in the transaction
+// before the statement. A COMMIT of a transaction in which a statement has failed is counted as a rollback:
+// Postgres responds to it with the ROLLBACK command tag rather than an error.
+func (p *PostgresParser) Transaction(statement string, failed bool, start, end time.Time) (string, time.Duration) {
+	var idle time.Duration
+	if p.inTransaction && !p.lastStatementEnd.IsZero() && start.After(p.lastStatementEnd) {
+		idle = start.Sub(p.lastStatementEnd)
+	}
+	p.lastStatementEnd = end
+
+	if _, q, ok := strings.Cut(statement, " AS "); ok && strings.HasPrefix(statement, "PREPARE ") {
+		statement = q
+	}
+	keyword, rest, _ := strings.Cut(strings.TrimSpace(statement), " ")
+	keyword = strings.ToUpper(strings.TrimSuffix(keyword, ";"))
+	rest = strings.ToUpper(strings.TrimSpace(rest))
+	switch keyword {
+	case "BEGIN", "START":
+		if !failed && !p.inTransaction {
+			p.inTransaction = true
+			p.transactionAborted = false
+		}
+		return "", idle
+	case "COMMIT", "END":
+		if !p.inTransaction {
+			return "", idle
+		}
+		aborted := p.transactionAborted
+		p.inTransaction, p.transactionAborted = false, false
+		if failed || aborted {
+			return PostgresTransactionRollback, idle
+		}
+		return PostgresTransactionCommit, idle
+	case "ROLLBACK", "ABORT":
+		if !p.inTransaction {
+			return "", idle
+		}
+		if strings.HasPrefix(rest, "TO ") { // rolling back to a savepoint makes the transaction usable again
+			if !failed {
+				p.transactionAborted = false
+			}
+			return "", idle
+		}
+		p.inTransaction, p.transactionAborted = false, false
+		return PostgresTransactionRollback, idle
+	}
+	if failed && p.inTransaction {
+		p.transactionAborted = true
+	}
+	return "", idle
+}
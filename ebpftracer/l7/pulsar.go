@@ -0,0 +1,333 @@
+package l7
+
+import (
+	"encoding/binary"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// https://pulsar.apache.org/docs/next/developing-binary-protocol/
+// https://github.com/apache/pulsar/blob/master/pulsar-common/src/main/proto/PulsarApi.proto
+
+const (
+	PulsarCommandSubscribe       = 4
+	PulsarCommandProducer        = 5
+	PulsarCommandSend            = 6
+	PulsarCommandSendReceipt     = 7
+	PulsarCommandSendError       = 8
+	PulsarCommandMessage         = 9
+	PulsarCommandAck             = 10
+	PulsarCommandCloseProducer   = 11
+	PulsarCommandCloseConsumer   = 12
+	PulsarCommandSuccess         = 13
+	PulsarCommandError           = 14
+	PulsarCommandProducerSuccess = 17
+
+	pulsarPendingGcInterval = uint64(10 * time.Minute)
+	pulsarMaxTopicIds       = 10000 // the limit of the producers and consumers tracked per connection
+)
+
+var pulsarServerErrors = []string{
+	"UnknownError", "MetadataError", "PersistenceError", "AuthenticationError", "AuthorizationError", "ConsumerBusy",
+	"ServiceNotReady", "ProducerBlockedQuotaExceededError", "ProducerBlockedQuotaExceededException", "ChecksumError",
+	"UnsupportedVersionError", "TopicNotFound", "SubscriptionNotFound", "ConsumerNotFound", "TooManyRequests",
+	"TopicTerminatedError", "ProducerBusy", "InvalidTopicName", "IncompatibleSchema", "ConsumerAssignError",
+	"TransactionCoordinatorNotFound", "InvalidTxnStatus", "NotAllowedError", "TransactionConflict",
+	"TransactionNotFound", "ProducerFenced",
+}
+
+type PulsarRequest struct {
+	Command  string // SEND, MESSAGE, ACK, PRODUCER or SUBSCRIBE
+	Topic    string
+	Messages int
+	Status   string // ok or the server error, e.g., TopicNotFound
+	Failed   bool
+	Error    string
+	Duration time.Duration // the time until the receipt of SEND or the response to PRODUCER or SUBSCRIBE
+
+	kernelTime uint64
+}
+
+// Operation returns the method label value for the message commands: produce, consume or ack
+func (r *PulsarRequest) Operation() string {
+	switch r.Command {
+	case "SEND":
+		return "produce"
+	case "MESSAGE":
+		return "consume"
+	case "ACK":
+		return "ack"
+	}
+	return ""
+}
+
+type pulsarSendKey struct {
+	producerId uint64
+	sequenceId uint64
+}
+
+type PulsarParser struct {
+	producers  map[uint64]string // producer_id -> topic
+	consumers  map[uint64]string // consumer_id -> topic
+	requests   map[uint64]*PulsarRequest
+	sends      map[pulsarSendKey]*PulsarRequest
+	lastGcTime uint64
+}
+
+func NewPulsarParser() *PulsarParser {
+	return &PulsarParser{
+		producers: map[uint64]string{},
+		consumers: map[uint64]string{},
+		requests:  map[uint64]*PulsarRequest{},
+		sends:     map[pulsarSendKey]*PulsarRequest{},
+	}
+}
+
+// Parse parses the frames sent (MethodPulsarClientFrames) or received (MethodPulsarServerFrames) by the client.
+// It returns the received and acknowledged messages, the messages confirmed by the broker, and the responses
+// to the PRODUCER and SUBSCRIBE commands.
+func (p *PulsarParser) Parse(method Method, payload []byte, kernelTime uint64) []PulsarRequest {
+	var res []PulsarRequest
+	for len(payload) >= 8 {
+		totalSize := int(binary.BigEndian.Uint32(payload))
+		commandSize := int(binary.BigEndian.Uint32(payload[4:]))
+		if commandSize+4 > totalSize {
+			break
+		}
+		command := payload[8:]
+		if len(command) > commandSize {
+			command = command[:commandSize]
+		}
+		if r := p.parseCommand(method, command, kernelTime); r != nil {
+			res = append(res, *r)
+		}
+		if 4+totalSize > len(payload) { // truncated
+			break
+		}
+		payload = payload[4+totalSize:]
+	}
+	p.gc(kernelTime)
+	return res
+}
+
+func (p *PulsarParser) parseCommand(method Method, data []byte, kernelTime uint64) *PulsarRequest {
+	var typ uint64
+	var command []byte
+	pulsarFields(data, func(num protowire.Number, v uint64, b []byte) {
+		switch {
+		case num == 1:
+			typ = v
+		case typ != 0 && uint64(num) == typ:
+			command = b
+		}
+	})
+	if command == nil {
+		return nil
+	}
+	switch typ {
+	case PulsarCommandCloseProducer, PulsarCommandCloseConsumer: // sent by the client or by the broker
+		pulsarFields(command, func(num protowire.Number, v uint64, b []byte) {
+			if num != 1 {
+				return
+			}
+			if typ == PulsarCommandCloseProducer {
+				delete(p.producers, v)
+			} else {
+				delete(p.consumers, v)
+			}
+		})
+		return nil
+	}
+	switch method {
+	case MethodPulsarClientFrames:
+		return p.parseClientCommand(typ, command, kernelTime)
+	case MethodPulsarServerFrames:
+		return p.parseServerCommand(typ, command, kernelTime)
+	}
+	return nil
+}
+
+func (p *PulsarParser) parseClientCommand(typ uint64, command []byte, kernelTime uint64) *PulsarRequest {
+	switch typ {
+	case PulsarCommandProducer, PulsarCommandSubscribe:
+		var topic string
+		var id, requestId uint64
+		idField, requestIdField := protowire.Number(2), protowire.Number(3) // CommandProducer
+		r := &PulsarRequest{Command: "PRODUCER", kernelTime: kernelTime}
+		if typ == PulsarCommandSubscribe {
+			idField, requestIdField = 4, 5 // CommandSubscribe
+			r.Command = "SUBSCRIBE"
+		}
+		pulsarFields(command, func(num protowire.Number, v uint64, b []byte) {
+			switch num {
+			case 1:
+				topic = string(b)
+			case idField:
+				id = v
+			case requestIdField:
+				requestId = v
+			}
+		})
+		if topic == "" {
+			return nil
+		}
+		r.Topic = topic
+		if typ == PulsarCommandProducer {
+			setPulsarTopic(p.producers, id, topic)
+		} else {
+			setPulsarTopic(p.consumers, id, topic)
+		}
+		p.requests[requestId] = r
+	case PulsarCommandSend:
+		k := pulsarSendKey{}
+		r := &PulsarRequest{Command: "SEND", Messages: 1, kernelTime: kernelTime}
+		pulsarFields(command, func(num protowire.Number, v uint64, b []byte) {
+			switch num {
+			case 1:
+				k.producerId = v
+			case 2:
+				k.sequenceId = v
+			case 3:
+				r.Messages = int(v)
+			}
+		})
+		r.Topic = p.producers[k.producerId]
+		p.sends[k] = r
+	case PulsarCommandAck:
+		r := &PulsarRequest{Command: "ACK", Status: "ok"}
+		pulsarFields(command, func(num protowire.Number, v uint64, b []byte) {
+			switch num {
+			case 1:
+				r.Topic = p.consumers[v]
+			case 3:
+				r.Messages++
+			}
+		})
+		if r.Messages == 0 { // truncated
+			r.Messages = 1
+		}
+		return r
+	}
+	return nil
+}
+
+func (p *PulsarParser) parseServerCommand(typ uint64, command []byte, kernelTime uint64) *PulsarRequest {
+	var id, sequenceId, serverError uint64
+	var errorMessage string
+	var r *PulsarRequest
+	switch typ {
+	case PulsarCommandMessage:
+		pulsarFields(command, func(num protowire.Number, v uint64, b []byte) {
+			if num == 1 {
+				id = v
+			}
+		})
+		return &PulsarRequest{Command: "MESSAGE", Topic: p.consumers[id], Messages: 1, Status: "ok"}
+	case PulsarCommandSendReceipt, PulsarCommandSendError:
+		pulsarFields(command, func(num protowire.Number, v uint64, b []byte) {
+			switch num {
+			case 1:
+				id = v
+			case 2:
+				sequenceId = v
+			case 3:
+				serverError = v
+			case 4:
+				errorMessage = string(b)
+			}
+		})
+		k := pulsarSendKey{producerId: id, sequenceId: sequenceId}
+		if r = p.sends[k]; r == nil {
+			return nil
+		}
+		delete(p.sends, k)
+	case PulsarCommandSuccess, PulsarCommandProducerSuccess, PulsarCommandError:
+		pulsarFields(command, func(num protowire.Number, v uint64, b []byte) {
+			switch num {
+			case 1:
+				id = v
+			case 2:
+				serverError = v
+			case 3:
+				errorMessage = string(b)
+			}
+		})
+		if r = p.requests[id]; r == nil {
+			return nil
+		}
+		delete(p.requests, id)
+	default:
+		return nil
+	}
+	r.Status = "ok"
+	if typ == PulsarCommandSendError || typ == PulsarCommandError {
+		r.Status = pulsarServerError(serverError)
+		r.Failed = true
+		r.Error = errorMessage
+	}
+	if kernelTime > r.kernelTime {
+		r.Duration = time.Duration(kernelTime - r.kernelTime)
+	}
+	return r
+}
+
+// gc removes the requests that have never been responded, e.g., because of the lost events
+func (p *PulsarParser) gc(kernelTime uint64) {
+	if kernelTime-p.lastGcTime < pulsarPendingGcInterval {
+		return
+	}
+	for id, r := range p.requests {
+		if kernelTime-r.kernelTime > pulsarPendingGcInterval {
+			delete(p.requests, id)
+		}
+	}
+	for k, r := range p.sends {
+		if kernelTime-r.kernelTime > pulsarPendingGcInterval {
+			delete(p.sends, k)
+		}
+	}
+	p.lastGcTime = kernelTime
+}
+
+// setPulsarTopic stores the topic of the producer or consumer unless the limit is reached, e.g., because of lost CLOSE commands
+func setPulsarTopic(topics map[uint64]string, id uint64, topic string) {
+	if _, ok := topics[id]; !ok && len(topics) >= pulsarMaxTopicIds {
+		return
+	}
+	topics[id] = topic
+}
+
+func pulsarServerError(code uint64) string {
+	if code < uint64(len(pulsarServerErrors)) {
+		return pulsarServerErrors[code]
+	}
+	return "ServerError:" + strconv.FormatUint(code, 10)
+}
+
+// pulsarFields calls f for each varint and length-delimited field of the protobuf message read before the end of data
+func pulsarFields(data []byte, f func(num protowire.Number, v uint64, b []byte)) {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return
+		}
+		data = data[n:]
+		var v uint64
+		var b []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+		case protowire.BytesType:
+			b, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return
+		}
+		f(num, v, b)
+		data = data[n:]
+	}
+}
@@ -0,0 +1,65 @@
+package l7
+
+import (
+	"encoding/binary"
+)
+
+// AMQP 0-9-1 Protocol Specification
+// https://www.rabbitmq.com/protocol.html
+
+const (
+	rabbitmqFrameTypeMethod = 1
+	rabbitmqClassBasic      = 60
+	rabbitmqMethodPublish   = 40
+	rabbitmqMethodDeliver   = 60
+
+	rabbitmqFrameHeaderLength = 7
+)
+
+// ParseRabbitmq returns the exchange and the routing key of a basic.publish or basic.deliver frame
+func ParseRabbitmq(payload []byte) (string, string) {
+	if len(payload) < rabbitmqFrameHeaderLength+4 || payload[0] != rabbitmqFrameTypeMethod {
+		return "", ""
+	}
+	args := payload[rabbitmqFrameHeaderLength:]
+	if binary.BigEndian.Uint16(args) != rabbitmqClassBasic {
+		return "", ""
+	}
+	method := binary.BigEndian.Uint16(args[2:])
+	args = args[4:]
+	switch method {
+	case rabbitmqMethodPublish:
+		if len(args) < 2 {
+			return "", ""
+		}
+		args = args[2:] // reserved-1
+	case rabbitmqMethodDeliver:
+		var ok bool
+		if _, args, ok = rabbitmqReadShortString(args); !ok { // consumer-tag
+			return "", ""
+		}
+		if len(args) < 9 {
+			return "", ""
+		}
+		args = args[9:] // delivery-tag, redelivered
+	default:
+		return "", ""
+	}
+	exchange, args, ok := rabbitmqReadShortString(args)
+	if !ok {
+		return "", ""
+	}
+	routingKey, _, _ := rabbitmqReadShortString(args)
+	return exchange, routingKey
+}
+
+func rabbitmqReadShortString(data []byte) (string, []byte, bool) {
+	if len(data) < 1 {
+		return "", nil, false
+	}
+	l := int(data[0])
+	if len(data) < 1+l {
+		return "", nil, false
+	}
+	return string(data[1 : 1+l]), data[1+l:], true
+}
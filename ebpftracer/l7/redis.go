@@ -3,39 +3,140 @@ package l7
 import (
 	"bytes"
 	"strconv"
+	"strings"
 )
 
+const redisMaxPipelineCommands = 100
+
+// error prefixes reported in the status label, the rest of the errors are reported as "failed"
+var redisErrors = map[string]string{
+	"MOVED":       "moved",
+	"ASK":         "ask",
+	"WRONGTYPE":   "wrongtype",
+	"OOM":         "oom",
+	"NOSCRIPT":    "noscript",
+	"BUSY":        "busy",
+	"LOADING":     "loading",
+	"READONLY":    "readonly",
+	"CLUSTERDOWN": "clusterdown",
+	"TRYAGAIN":    "tryagain",
+	"NOAUTH":      "noauth",
+	"NOPERM":      "noperm",
+	"EXECABORT":   "execabort",
+}
+
+type RedisCommand struct {
+	Cmd  string
+	Args string
+}
+
+type RedisParser struct {
+	lastError string
+}
+
+func NewRedisParser() *RedisParser {
+	return &RedisParser{}
+}
+
+// ParseErrorResponse parses a simple error (-MOVED 3999 127.0.0.1:6381) or a RESP3 blob error (!21\r\nSYNTAX invalid syntax)
+// that precedes the failed request
+func (p *RedisParser) ParseErrorResponse(payload []byte) {
+	p.lastError = ""
+	if len(payload) < 2 {
+		return
+	}
+	var msg []byte
+	switch payload[0] {
+	case '-':
+		msg, _, _ = bytes.Cut(payload[1:], crlf)
+	case '!':
+		_, msg, _ = bytes.Cut(payload[1:], crlf)
+	default:
+		return
+	}
+	prefix, _, _ := bytes.Cut(msg, []byte(" "))
+	p.lastError = string(prefix)
+}
+
+// Status returns the status of the request: ok, the lower-cased error prefix (e.g., moved, wrongtype), or failed
+func (p *RedisParser) Status(status Status) (string, string) {
+	if !status.Error() {
+		return status.String(), ""
+	}
+	prefix := p.lastError
+	p.lastError = ""
+	if s, ok := redisErrors[prefix]; ok {
+		return s, prefix
+	}
+	return status.String(), prefix
+}
+
 func ParseRedis(payload []byte) (cmd string, args string) {
-	var v, rest []byte
-	var ok bool
-	v, rest, ok = bytes.Cut(payload, crlf)
-	if !ok || !bytes.HasPrefix(v, []byte("*")) {
+	commands := ParseRedisPipeline(payload)
+	if len(commands) == 0 {
 		return
 	}
+	return commands[0].Cmd, commands[0].Args
+}
+
+// ParseRedisPipeline returns the commands of a pipelined request read before the end of the (usually truncated) payload
+func ParseRedisPipeline(payload []byte) []RedisCommand {
+	var commands []RedisCommand
+	for len(payload) > 0 && len(commands) < redisMaxPipelineCommands {
+		var c RedisCommand
+		var ok bool
+		c, payload, ok = parseRedisCommand(payload)
+		if c.Cmd != "" {
+			commands = append(commands, c)
+		}
+		if !ok {
+			break
+		}
+	}
+	return commands
+}
+
+// parseRedisCommand reads an array of bulk strings: *<n>\r\n$<len>\r\n<data>\r\n...
+func parseRedisCommand(payload []byte) (RedisCommand, []byte, bool) {
+	var c RedisCommand
+	v, rest, ok := bytes.Cut(payload, crlf)
+	if !ok || !bytes.HasPrefix(v, []byte("*")) {
+		return c, nil, false
+	}
 	arrayLen, err := strconv.ParseUint(string(v[1:]), 10, 32)
-	if err != nil {
-		return
+	if err != nil || arrayLen == 0 {
+		return c, nil, false
 	}
-	readString := func() string {
+	readString := func() (string, bool) {
 		v, rest, ok = bytes.Cut(rest, crlf)
 		if !ok || !bytes.HasPrefix(v, []byte("$")) {
-			return ""
+			return "", false
 		}
-		v, rest, ok = bytes.Cut(rest, crlf)
-		if ok {
-			return string(v)
+		l, err := strconv.ParseUint(string(v[1:]), 10, 32)
+		if err != nil {
+			return "", false
+		}
+		if uint64(len(rest)) < l+2 {
+			rest = nil
+			return "", false
 		}
-		return ""
+		s := string(rest[:l])
+		rest = rest[l+2:]
+		return s, true
 	}
-	cmd = readString()
-	if cmd == "" {
-		return
+	cmd, ok := readString()
+	if !ok || cmd == "" {
+		return c, nil, false
 	}
+	c.Cmd = strings.ToUpper(cmd)
 	if arrayLen > 1 {
-		args = readString()
+		c.Args, ok = readString()
 		if arrayLen > 2 {
-			args += " ..."
+			c.Args += " ..."
 		}
 	}
-	return
+	for i := uint64(2); ok && i < arrayLen; i++ {
+		_, ok = readString()
+	}
+	return c, rest, ok
 }
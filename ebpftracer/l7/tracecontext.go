@@ -0,0 +1,135 @@
+package l7
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// https://www.w3.org/TR/trace-context/
+// https://github.com/openzipkin/b3-propagation
+
+// TraceContext is the context of the span that has sent the request propagated in the request headers
+type TraceContext struct {
+	TraceId    [16]byte
+	SpanId     [8]byte
+	Sampled    bool
+	TraceState string
+}
+
+// TraceHeaders collects the W3C Trace Context and B3 headers of a request
+type TraceHeaders struct {
+	traceparent string
+	tracestate  string
+	b3          string
+	b3TraceId   string
+	b3SpanId    string
+	b3Sampled   string
+	b3Flags     string
+}
+
+// Set stores the value of the header if it's a propagation header, the name must be lowercase
+func (h *TraceHeaders) Set(name, value string) {
+	switch name {
+	case "traceparent":
+		h.traceparent = value
+	case "tracestate":
+		h.tracestate = value
+	case "b3":
+		h.b3 = value
+	case "x-b3-traceid":
+		h.b3TraceId = value
+	case "x-b3-spanid":
+		h.b3SpanId = value
+	case "x-b3-sampled":
+		h.b3Sampled = value
+	case "x-b3-flags":
+		h.b3Flags = value
+	}
+}
+
+// TraceContext returns the propagated context (traceparent takes precedence over B3) or nil if there is no valid one
+func (h *TraceHeaders) TraceContext() *TraceContext {
+	if h.traceparent != "" {
+		if tc := parseTraceparent(h.traceparent); tc != nil {
+			tc.TraceState = h.tracestate
+			return tc
+		}
+	}
+	if h.b3 != "" {
+		// {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}, the last two fields are optional
+		parts := strings.Split(h.b3, "-")
+		if len(parts) < 2 {
+			return nil // b3: 0 means "do not sample" without a context
+		}
+		sampled := ""
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+		return newB3TraceContext(parts[0], parts[1], sampled)
+	}
+	if h.b3TraceId != "" {
+		sampled := h.b3Sampled
+		if h.b3Flags == "1" {
+			sampled = "d"
+		}
+		return newB3TraceContext(h.b3TraceId, h.b3SpanId, sampled)
+	}
+	return nil
+}
+
+// ParseHttpTraceContext returns the trace context propagated in the headers of the HTTP/1.x request
+func ParseHttpTraceContext(payload []byte) *TraceContext {
+	var h TraceHeaders
+	httpHeaders(payload, h.Set)
+	return h.TraceContext()
+}
+
+// version-trace_id-parent_id-trace_flags, e.g. 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01
+func parseTraceparent(s string) *TraceContext {
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return nil
+	}
+	if s[:2] == "ff" || (s[:2] == "00" && len(s) != 55) || (len(s) > 55 && s[55] != '-') {
+		return nil
+	}
+	var tc TraceContext
+	var flags [1]byte
+	if !decodeHex(tc.TraceId[:], s[3:35]) || !decodeHex(tc.SpanId[:], s[36:52]) || !decodeHex(flags[:], s[53:55]) {
+		return nil
+	}
+	if !tc.valid() {
+		return nil
+	}
+	tc.Sampled = flags[0]&1 == 1
+	return &tc
+}
+
+func newB3TraceContext(traceId, spanId, sampled string) *TraceContext {
+	var tc TraceContext
+	if len(traceId) == 16 { // 64-bit trace IDs are left-padded
+		traceId = strings.Repeat("0", 16) + traceId
+	}
+	if len(traceId) != 32 || len(spanId) != 16 || !decodeHex(tc.TraceId[:], traceId) || !decodeHex(tc.SpanId[:], spanId) {
+		return nil
+	}
+	if !tc.valid() {
+		return nil
+	}
+	switch sampled {
+	case "1", "true", "d", "": // the decision is deferred if the sampling state is missing
+		tc.Sampled = true
+	}
+	return &tc
+}
+
+func (tc *TraceContext) valid() bool {
+	return tc.TraceId != [16]byte{} && tc.SpanId != [8]byte{}
+}
+
+func decodeHex(dst []byte, s string) bool {
+	if strings.ToLower(s) != s {
+		return false
+	}
+	n, err := hex.Decode(dst, []byte(s))
+	return err == nil && n == len(dst)
+}
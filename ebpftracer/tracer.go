@@ -44,6 +44,7 @@ const (
 	EventTypeTCPRetransmit    EventType = 9
 	EventTypeL7Request        EventType = 10
 	EventTypePythonThreadLock EventType = 11
+	EventTypeConnectionAccept EventType = 12
 
 	EventReasonNone    EventReason = 0
 	EventReasonOOMKill EventReason = 1
@@ -250,6 +251,8 @@ func (t *Tracer) ebpf(ch chan<- Event) error {
 				continue
 			case "sys_exit_read", "sys_exit_readv", "sys_exit_recvfrom", "sys_exit_recvmsg":
 				continue
+			case "sys_exit_accept", "sys_exit_accept4", "inet_csk_accept":
+				continue
 			}
 		}
 		var l link.Link
@@ -262,7 +265,11 @@ func (t *Tracer) ebpf(ch chan<- Event) error {
 				t.uprobes[programSpec.Name] = program
 				continue
 			}
-			l, err = link.Kprobe(programSpec.AttachTo, program, nil)
+			if strings.HasPrefix(programSpec.SectionName, "kretprobe/") {
+				l, err = link.Kretprobe(programSpec.AttachTo, program, nil)
+			} else {
+				l, err = link.Kprobe(programSpec.AttachTo, program, nil)
+			}
 			if err != nil && programSpec.SectionName == "kprobe/nf_ct_deliver_cached_events" {
 				klog.Warningln("nf_conntrack may not be in use:", err)
 				continue
@@ -300,6 +307,8 @@ func (t EventType) String() string {
 		return "tcp-retransmit"
 	case EventTypeL7Request:
 		return "l7-request"
+	case EventTypeConnectionAccept:
+		return "connection-accept"
 	}
 	return "unknown: " + strconv.Itoa(int(t))
 }
@@ -352,9 +361,11 @@ type l7Event struct {
 	Duration            uint64
 	Protocol            uint8
 	Method              uint8
-	Padding             uint16
+	Inbound             uint8
+	Padding             uint8
 	StatementId         uint32
 	PayloadSize         uint64
+	KernelTime          uint64
 }
 
 type pythonThreadEvent struct {
@@ -397,13 +408,12 @@ func runEventsReader(name string, r *perf.Reader, ch chan<- Event, typ perfMapTy
 				Duration:    time.Duration(v.Duration),
 				Method:      l7.Method(v.Method),
 				StatementId: v.StatementId,
+				Inbound:     v.Inbound > 0,
+				KernelTime:  v.KernelTime,
 			}
-			switch {
-			case v.PayloadSize == 0:
-			case v.PayloadSize > MaxPayloadSize:
-				req.Payload = payload[:MaxPayloadSize]
-			default:
-				req.Payload = payload[:v.PayloadSize]
+			// the sample may be shorter than expected if the eBPF program has been built with another l7_event layout
+			if size := min(v.PayloadSize, MaxPayloadSize, uint64(len(payload))); size > 0 {
+				req.Payload = payload[:size]
 			}
 			event = Event{Type: EventTypeL7Request, Pid: v.Pid, Fd: v.Fd, Timestamp: v.ConnectionTimestamp, L7Request: req}
 		case perfMapTypeFileEvents:
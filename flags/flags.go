@@ -19,6 +19,12 @@ var (
 	ContainerDenylist  = kingpin.Flag("container-denylist", "List of denied containers (regex patterns)").Envar("CONTAINER_DENYLIST").Strings()
 
 	ExcludeHTTPMetricsByPath = kingpin.Flag("exclude-http-requests-by-path", "Skip HTTP metrics and traces by path").Envar("EXCLUDE_HTTP_REQUESTS_BY_PATH").Strings()
+	HTTPRouteTemplates       = kingpin.Flag("http-route-template", "HTTP route templates (e.g., /api/users/{id}); other paths are normalized by replacing ids, UUIDs, and hashes with placeholders").Envar("HTTP_ROUTE_TEMPLATES").Strings()
+	HTTPRouteLabels          = kingpin.Flag("http-route-labels", "Add `method` and `route` labels to HTTP metrics").Default("false").Envar("HTTP_ROUTE_LABELS").Bool()
+	RedisCommandLabels       = kingpin.Flag("redis-command-labels", "Add the `command` label to Redis metrics (pipelined requests are reported with command=pipeline)").Default("false").Envar("REDIS_COMMAND_LABELS").Bool()
+	MongoCommandLabels       = kingpin.Flag("mongo-command-labels", "Add `command`, `db`, and `collection` labels to Mongo metrics").Default("false").Envar("MONGO_COMMAND_LABELS").Bool()
+	CloudAPILabels           = kingpin.Flag("cloud-api-labels", "Add `cloud_service` and `cloud_operation` labels to HTTP metrics for the calls to AWS, GCP, and Azure APIs").Default("false").Envar("CLOUD_API_LABELS").Bool()
+	GraphqlLabels            = kingpin.Flag("graphql-labels", "Add `graphql_operation_type` and `graphql_operation` labels to HTTP metrics for GraphQL requests").Default("false").Envar("GRAPHQL_LABELS").Bool()
 
 	ExternalNetworksWhitelist = kingpin.
 					Flag("track-public-network", "Allow track connections to the specified IP networks, all private networks are allowed by default (e.g., Y.Y.Y.Y/mask)").
@@ -35,7 +41,9 @@ var (
 	LogPerSecond      = kingpin.Flag("log-per-second", "The number of logs per second").Default("10.0").Envar("LOG_PER_SECOND").Float64()
 	LogBurst          = kingpin.Flag("log-burst", "The maximum number of tokens that can be consumed in a single call to allow").Default("100").Envar("LOG_BURST").Int()
 
-	MaxLabelLength = kingpin.Flag("max-label-length", "Maximum length of a metric label value").Default("4096").Envar("MAX_LABEL_LENGTH").Int()
+	MaxLabelLength   = kingpin.Flag("max-label-length", "Maximum length of a metric label value").Default("4096").Envar("MAX_LABEL_LENGTH").Int()
+	MaxL7LabelValues = kingpin.Flag("max-l7-label-values", "Maximum number of distinct values of an L7 metric label (e.g., Kafka topic) per destination, the rest are reported as 'other'").Default("100").Envar("MAX_L7_LABEL_VALUES").Int()
+	DbStatementsTopN = kingpin.Flag("db-statements-top-n", "Number of the most time-consuming Postgres, MySQL, ClickHouse, and MSSQL statements to report per destination (0 disables statement stats)").Default("0").Envar("DB_STATEMENTS_TOP_N").Int()
 
 	CollectorEndpoint  = kingpin.Flag("collector-endpoint", "A base endpoint URL for metrics, traces, logs, and profiles").Envar("COLLECTOR_ENDPOINT").URL()
 	ApiKey             = kingpin.Flag("api-key", "Coroot API key").Envar("API_KEY").String()
@@ -45,6 +53,14 @@ var (
 	ProfilesEndpoint   = kingpin.Flag("profiles-endpoint", "The URL of the endpoint to send profiles to").Envar("PROFILES_ENDPOINT").URL()
 	InsecureSkipVerify = kingpin.Flag("insecure-skip-verify", "whether to skip verifying the certificate or not").Envar("INSECURE_SKIP_VERIFY").Default("false").Bool()
 
+	TracesStatementObfuscation = kingpin.Flag("traces-statement-obfuscation", "How database statements are recorded in spans: off, obfuscate (literals are replaced with ?), or drop-statement; keys, znode paths and server error messages are recorded only if off").Default("off").Envar("TRACES_STATEMENT_OBFUSCATION").Enum("off", "obfuscate", "drop-statement")
+	TracesSamplingRatio        = kingpin.Flag("traces-sampling-ratio", "The ratio of traces to sample (from 0 to 1)").Default("1.0").Envar("TRACES_SAMPLING_RATIO").Float64()
+	TracesSamplingRules        = kingpin.Flag("traces-sampling-rule", "Sampling ratio for a service and protocol in the <service>:<protocol>=<ratio> format, where <service> is a regex or '*' and <protocol> is a protocol name (e.g., HTTP, Redis) or '*' (e.g., '*:Redis=0.01'); the first matching rule wins").Envar("TRACES_SAMPLING_RULES").Strings()
+	TracesKeepErrors           = kingpin.Flag("traces-keep-errors", "Keep the spans of failed requests regardless of the sampling ratio").Default("true").Envar("TRACES_KEEP_ERRORS").Bool()
+	TracesKeepSlowerThan       = kingpin.Flag("traces-keep-slower-than", "Keep the spans of requests slower than this regardless of the sampling ratio (0 disables)").Default("0s").Envar("TRACES_KEEP_SLOWER_THAN").Duration()
+	TracesMaxSpansPerSecond    = kingpin.Flag("traces-max-spans-per-second", "Maximum number of spans per second sent for a container (0 means unlimited)").Default("0").Envar("TRACES_MAX_SPANS_PER_SECOND").Float64()
+	TracesSamplingConfig       = kingpin.Flag("traces-sampling-config", "Path to a YAML file with the sampling settings (ratio, keep_errors, keep_slower_than, max_spans_per_second, and rules), overriding the corresponding flags").Envar("TRACES_SAMPLING_CONFIG").String()
+
 	ScrapeInterval = kingpin.Flag("scrape-interval", "How often to gather metrics from the agent").Default("15s").Envar("SCRAPE_INTERVAL").Duration()
 	WalDir         = kingpin.Flag("wal-dir", "Path to where the agent stores data (e.g. the metrics Write-Ahead Log)").Default("/tmp/coroot-node-agent").Envar("WAL_DIR").String()
 	MaxSpoolSize   = kingpin.Flag("max-spool-size", "Maximum size of the on-disk spool used to buffer data when it cannot be sent to collector. Supports size suffixes like KB, MB, or GB.").Default("500MB").Envar("MAX_SPOOL_SIZE").Bytes()
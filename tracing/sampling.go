@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coroot/coroot-node-agent/ebpftracer/l7"
+	"github.com/coroot/coroot-node-agent/flags"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v2"
+)
+
+// SamplingConfig defines which spans are sent to the collector.
+// A span is kept if it's an error or a slow one (when enabled) or if it's chosen by head sampling
+// with the ratio of the first rule matching its service and protocol (or with the default ratio).
+// The spans of a propagated trace are sampled by its trace ID, so the agents with the same ratio make the same decision.
+// The per-container rate limit applies to all spans, including the always-kept ones.
+type SamplingConfig struct {
+	Ratio             float64        `yaml:"ratio"`
+	KeepErrors        bool           `yaml:"keep_errors"`
+	KeepSlowerThan    time.Duration  `yaml:"keep_slower_than"`
+	MaxSpansPerSecond float64        `yaml:"max_spans_per_second"`
+	Rules             []SamplingRule `yaml:"rules"`
+}
+
+type SamplingRule struct {
+	Service  string  `yaml:"service"`  // a regex matched against the service name, empty matches any service
+	Protocol string  `yaml:"protocol"` // e.g., HTTP, gRPC, Postgres (case-insensitive), empty matches any protocol
+	Ratio    float64 `yaml:"ratio"`
+
+	serviceRe *regexp.Regexp
+}
+
+// loadSamplingConfig builds the config from the flags and overrides it with the settings defined in the config file (if any)
+func loadSamplingConfig() (*SamplingConfig, error) {
+	cfg := &SamplingConfig{
+		Ratio:             *flags.TracesSamplingRatio,
+		KeepErrors:        *flags.TracesKeepErrors,
+		KeepSlowerThan:    *flags.TracesKeepSlowerThan,
+		MaxSpansPerSecond: *flags.TracesMaxSpansPerSecond,
+	}
+	for _, s := range *flags.TracesSamplingRules {
+		r, err := parseSamplingRule(s)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Rules = append(cfg.Rules, r)
+	}
+	if path := *flags.TracesSamplingConfig; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.UnmarshalStrict(data, cfg); err != nil {
+			return nil, fmt.Errorf("invalid sampling config %s: %w", path, err)
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseSamplingRule parses a rule in the <service>:<protocol>=<ratio> format, where <service> or <protocol> can be '*'
+func parseSamplingRule(s string) (SamplingRule, error) {
+	var r SamplingRule
+	eq := strings.LastIndexByte(s, '=')
+	colon := strings.LastIndexByte(s[:max(eq, 0)], ':')
+	if eq < 0 || colon < 0 {
+		return r, fmt.Errorf("invalid sampling rule %q: must be <service>:<protocol>=<ratio>", s)
+	}
+	var err error
+	if r.Ratio, err = strconv.ParseFloat(s[eq+1:], 64); err != nil {
+		return r, fmt.Errorf("invalid sampling rule %q: %w", s, err)
+	}
+	r.Service, r.Protocol = s[:colon], s[colon+1:eq]
+	if r.Service == "*" {
+		r.Service = ""
+	}
+	if r.Protocol == "*" {
+		r.Protocol = ""
+	}
+	return r, nil
+}
+
+func (cfg *SamplingConfig) validate() error {
+	if cfg.Ratio < 0 || cfg.Ratio > 1 {
+		return fmt.Errorf("invalid sampling ratio %v: must be between 0 and 1", cfg.Ratio)
+	}
+	if cfg.MaxSpansPerSecond < 0 {
+		return fmt.Errorf("invalid max spans per second %v", cfg.MaxSpansPerSecond)
+	}
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if r.Ratio < 0 || r.Ratio > 1 {
+			return fmt.Errorf("invalid sampling ratio %v of the rule for %s:%s: must be between 0 and 1", r.Ratio, r.Service, r.Protocol)
+		}
+		if r.Service != "" {
+			re, err := regexp.Compile(r.Service)
+			if err != nil {
+				return fmt.Errorf("invalid service pattern of the sampling rule: %w", err)
+			}
+			r.serviceRe = re
+		}
+	}
+	return nil
+}
+
+// sampler makes the sampling decisions for the spans of a container
+type sampler struct {
+	cfg     *SamplingConfig
+	rules   []SamplingRule // the rules matching the service of the container
+	limiter *rate.Limiter
+}
+
+func newSampler(cfg *SamplingConfig, serviceName string) *sampler {
+	s := &sampler{cfg: cfg}
+	for _, r := range cfg.Rules {
+		if r.serviceRe == nil || r.serviceRe.MatchString(serviceName) {
+			s.rules = append(s.rules, r)
+		}
+	}
+	if cfg.MaxSpansPerSecond > 0 {
+		s.limiter = rate.NewLimiter(rate.Limit(cfg.MaxSpansPerSecond), max(1, int(cfg.MaxSpansPerSecond)))
+	}
+	return s
+}
+
+func (s *sampler) ratio(protocol l7.Protocol) float64 {
+	for _, r := range s.rules {
+		if r.Protocol == "" || strings.EqualFold(r.Protocol, protocol.String()) {
+			return r.Ratio
+		}
+	}
+	return s.cfg.Ratio
+}
+
+func (s *sampler) sample(parent *l7.TraceContext, protocol l7.Protocol, duration time.Duration, error bool) bool {
+	keep := (s.cfg.KeepErrors && error) || (s.cfg.KeepSlowerThan > 0 && duration >= s.cfg.KeepSlowerThan)
+	if !keep && !headSample(parent, s.ratio(protocol)) {
+		return false
+	}
+	return s.limiter == nil || s.limiter.Allow()
+}
+
+// headSample follows the decision of the upstream if it has dropped the trace,
+// otherwise it samples by the trace ID the same way as the TraceIDRatioBased sampler of the OpenTelemetry SDK does
+func headSample(parent *l7.TraceContext, ratio float64) bool {
+	switch {
+	case ratio <= 0:
+		return false
+	case parent != nil && !parent.Sampled:
+		return false
+	case ratio >= 1:
+		return true
+	case parent != nil:
+		return binary.BigEndian.Uint64(parent.TraceId[8:16])>>1 < uint64(ratio*(1<<63))
+	}
+	return rand.Float64() < ratio
+}
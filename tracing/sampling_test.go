@@ -0,0 +1,191 @@
+package tracing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coroot/coroot-node-agent/ebpftracer/l7"
+	"github.com/coroot/coroot-node-agent/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSamplingRule(t *testing.T) {
+	for _, c := range []struct {
+		rule     string
+		expected SamplingRule
+		err      bool
+	}{
+		{rule: "/k8s/default/api:Redis=0.01", expected: SamplingRule{Service: "/k8s/default/api", Protocol: "Redis", Ratio: 0.01}},
+		{rule: "*:*=0.5", expected: SamplingRule{Ratio: 0.5}},
+		{rule: "api:*=1", expected: SamplingRule{Service: "api", Ratio: 1}},
+		{rule: "*:HTTP=0", expected: SamplingRule{Protocol: "HTTP"}},
+		{rule: "redis=0.5", err: true},
+		{rule: "api:redis", err: true},
+		{rule: "api:redis=x", err: true},
+	} {
+		r, err := parseSamplingRule(c.rule)
+		if c.err {
+			assert.Error(t, err, c.rule)
+			continue
+		}
+		require.NoError(t, err, c.rule)
+		assert.Equal(t, c.expected, r, c.rule)
+	}
+}
+
+func TestLoadSamplingConfig(t *testing.T) {
+	setSamplingFlags(t, 1, []string{"api:redis=0", "*:*=0.5"}, "")
+	cfg, err := loadSamplingConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, cfg.Ratio)
+	assert.True(t, cfg.KeepErrors)
+	assert.Len(t, cfg.Rules, 2)
+
+	// the settings defined in the config file override the flags
+	path := filepath.Join(t.TempDir(), "sampling.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+ratio: 0.2
+keep_errors: false
+keep_slower_than: 500ms
+max_spans_per_second: 2
+rules:
+- service: ^/k8s/default/.*
+  protocol: Postgres
+  ratio: 0.1
+`), 0644))
+	setSamplingFlags(t, 1, []string{"api:redis=0"}, path)
+	cfg, err = loadSamplingConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 0.2, cfg.Ratio)
+	assert.False(t, cfg.KeepErrors)
+	assert.Equal(t, 500*time.Millisecond, cfg.KeepSlowerThan)
+	assert.Equal(t, 2.0, cfg.MaxSpansPerSecond)
+	require.Len(t, cfg.Rules, 1)
+	assert.Equal(t, "Postgres", cfg.Rules[0].Protocol)
+
+	for _, invalid := range []string{
+		"foo: 1\n", // unknown fields are rejected
+		"ratio: 2\n",
+		"max_spans_per_second: -1\n",
+		"rules:\n- service: '('\n  ratio: 0.5\n",
+		"rules:\n- ratio: 1.5\n",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(invalid), 0644))
+		_, err = loadSamplingConfig()
+		assert.Error(t, err, invalid)
+	}
+
+	setSamplingFlags(t, 1, nil, filepath.Join(t.TempDir(), "missing.yaml"))
+	_, err = loadSamplingConfig()
+	assert.Error(t, err)
+}
+
+func TestSamplerRules(t *testing.T) {
+	cfg := validSamplingConfig(t, &SamplingConfig{
+		Ratio: 0.3,
+		Rules: []SamplingRule{
+			{Service: "^/k8s/default/api$", Protocol: "redis", Ratio: 0},
+			{Service: "^/k8s/default/", Protocol: "", Ratio: 0.5},
+			{Service: "", Protocol: "Postgres", Ratio: 0.1},
+		},
+	})
+	for _, c := range []struct {
+		service  string
+		protocol l7.Protocol
+		ratio    float64
+	}{
+		{service: "/k8s/default/api", protocol: l7.ProtocolRedis, ratio: 0},
+		{service: "/k8s/default/api", protocol: l7.ProtocolHTTP, ratio: 0.5},
+		{service: "/k8s/default/api", protocol: l7.ProtocolPostgres, ratio: 0.5}, // the first matching rule wins
+		{service: "/k8s/default/web", protocol: l7.ProtocolRedis, ratio: 0.5},
+		{service: "/k8s/prod/api", protocol: l7.ProtocolRedis, ratio: 0.3},
+		{service: "/k8s/prod/api", protocol: l7.ProtocolPostgres, ratio: 0.1},
+	} {
+		assert.Equal(t, c.ratio, newSampler(cfg, c.service).ratio(c.protocol), c.service+" "+c.protocol.String())
+	}
+}
+
+func TestSamplerKeep(t *testing.T) {
+	cfg := validSamplingConfig(t, &SamplingConfig{Ratio: 0, KeepErrors: true, KeepSlowerThan: 500 * time.Millisecond})
+	s := newSampler(cfg, "api")
+	assert.False(t, s.sample(nil, l7.ProtocolHTTP, time.Millisecond, false))
+	assert.True(t, s.sample(nil, l7.ProtocolHTTP, time.Millisecond, true))
+	assert.True(t, s.sample(nil, l7.ProtocolHTTP, 500*time.Millisecond, false))
+	// an upstream decision to drop the trace doesn't apply to errors and slow requests
+	assert.True(t, s.sample(&l7.TraceContext{Sampled: false}, l7.ProtocolHTTP, time.Second, false))
+
+	cfg = validSamplingConfig(t, &SamplingConfig{Ratio: 0})
+	s = newSampler(cfg, "api")
+	assert.False(t, s.sample(nil, l7.ProtocolHTTP, time.Millisecond, true))
+	assert.False(t, s.sample(nil, l7.ProtocolHTTP, time.Hour, false))
+}
+
+func TestSamplerLimit(t *testing.T) {
+	cfg := validSamplingConfig(t, &SamplingConfig{Ratio: 1, KeepErrors: true, MaxSpansPerSecond: 2})
+	s1, s2 := newSampler(cfg, "api"), newSampler(cfg, "web")
+	assert.True(t, s1.sample(nil, l7.ProtocolHTTP, time.Millisecond, false))
+	assert.True(t, s1.sample(nil, l7.ProtocolHTTP, time.Millisecond, false))
+	assert.False(t, s1.sample(nil, l7.ProtocolHTTP, time.Millisecond, false))
+	assert.False(t, s1.sample(nil, l7.ProtocolHTTP, time.Millisecond, true)) // the limit applies to errors too
+	assert.True(t, s2.sample(nil, l7.ProtocolHTTP, time.Millisecond, false)) // the limit is per container
+
+	cfg = validSamplingConfig(t, &SamplingConfig{Ratio: 1})
+	s := newSampler(cfg, "api")
+	for i := 0; i < 1000; i++ {
+		require.True(t, s.sample(nil, l7.ProtocolHTTP, time.Millisecond, false))
+	}
+}
+
+func TestHeadSample(t *testing.T) {
+	traceContext := func(sampled bool, b byte) *l7.TraceContext {
+		tc := &l7.TraceContext{Sampled: sampled}
+		tc.TraceId[8] = b
+		return tc
+	}
+	for _, c := range []struct {
+		parent   *l7.TraceContext
+		ratio    float64
+		expected bool
+	}{
+		{parent: nil, ratio: 0, expected: false},
+		{parent: nil, ratio: 1, expected: true},
+		{parent: traceContext(true, 0x10), ratio: 0.5, expected: true},
+		{parent: traceContext(true, 0xf0), ratio: 0.5, expected: false},
+		{parent: traceContext(true, 0xf0), ratio: 1, expected: true},
+		{parent: traceContext(true, 0x00), ratio: 0, expected: false},
+		{parent: traceContext(false, 0x00), ratio: 1, expected: false},
+	} {
+		assert.Equal(t, c.expected, headSample(c.parent, c.ratio), "%+v %v", c.parent, c.ratio)
+	}
+
+	// the same trace ID gets the same decision
+	tc := traceContext(true, 0x7f)
+	expected := headSample(tc, 0.5)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, expected, headSample(tc, 0.5))
+	}
+
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		if headSample(nil, 0.2) {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 2000, sampled, 300)
+}
+
+func validSamplingConfig(t *testing.T, cfg *SamplingConfig) *SamplingConfig {
+	require.NoError(t, cfg.validate())
+	return cfg
+}
+
+func setSamplingFlags(t *testing.T, ratio float64, rules []string, configPath string) {
+	prevRatio, prevRules, prevKeepErrors, prevConfig := *flags.TracesSamplingRatio, *flags.TracesSamplingRules, *flags.TracesKeepErrors, *flags.TracesSamplingConfig
+	t.Cleanup(func() {
+		*flags.TracesSamplingRatio, *flags.TracesSamplingRules, *flags.TracesKeepErrors, *flags.TracesSamplingConfig = prevRatio, prevRules, prevKeepErrors, prevConfig
+	})
+	*flags.TracesSamplingRatio, *flags.TracesSamplingRules, *flags.TracesKeepErrors, *flags.TracesSamplingConfig = ratio, rules, true, configPath
+}
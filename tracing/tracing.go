@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/coroot/coroot-node-agent/common"
@@ -28,6 +29,7 @@ var (
 	batcher             sdktrace.TracerProviderOption
 	commonResourceAttrs []attribute.KeyValue
 	agentVersion        string
+	samplingConfig      *SamplingConfig
 	initialized         bool
 )
 
@@ -56,6 +58,9 @@ func Init(machineId, hostname, version string) {
 	if err != nil {
 		klog.Exitln(err)
 	}
+	if samplingConfig, err = loadSamplingConfig(); err != nil {
+		klog.Exitln(err)
+	}
 
 	batcher = sdktrace.WithBatcher(exporter)
 	commonResourceAttrs = []attribute.KeyValue{semconv.HostName(hostname), semconv.HostID(machineId)}
@@ -64,47 +69,96 @@ func Init(machineId, hostname, version string) {
 }
 
 type Tracer struct {
-	otel trace.Tracer
+	otel    trace.Tracer
+	sampler *sampler
 }
 
 func GetContainerTracer(containerId string) *Tracer {
 	if !initialized {
 		return &Tracer{otel: nil}
 	}
+	serviceName := common.ContainerIdToOtelServiceName(containerId)
 	provider := sdktrace.NewTracerProvider(
 		batcher,
+		sdktrace.WithSampler(sdktrace.AlwaysSample()), // the spans are sampled in createSpanOfKind
 		sdktrace.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
 			append(
 				commonResourceAttrs,
-				semconv.ServiceName(common.ContainerIdToOtelServiceName(containerId)),
+				semconv.ServiceName(serviceName),
 				semconv.ContainerID(containerId),
 			)...,
 		)),
 	)
-	return &Tracer{otel: provider.Tracer("coroot-node-agent", trace.WithInstrumentationVersion(agentVersion))}
+	return &Tracer{
+		otel:    provider.Tracer("coroot-node-agent", trace.WithInstrumentationVersion(agentVersion)),
+		sampler: newSampler(samplingConfig, serviceName),
+	}
 }
 
 func (t *Tracer) NewTrace(destination common.HostPort) *Trace {
-	return &Trace{tracer: t, destination: destination, commonAttrs: []attribute.KeyValue{
+	return &Trace{tracer: t, destination: destination, kind: trace.SpanKindClient, commonAttrs: []attribute.KeyValue{
 		semconv.NetPeerName(destination.Host()),
 		semconv.NetPeerPort(int(destination.Port())),
 	}}
 }
 
+func (t *Tracer) NewServerTrace(listenAddr, client common.HostPort) *Trace {
+	return &Trace{tracer: t, destination: listenAddr, kind: trace.SpanKindServer, commonAttrs: []attribute.KeyValue{
+		semconv.NetHostName(listenAddr.Host()),
+		semconv.NetHostPort(int(listenAddr.Port())),
+		semconv.NetSockPeerAddr(client.Host()),
+		semconv.NetSockPeerPort(int(client.Port())),
+	}}
+}
+
 type Trace struct {
 	tracer      *Tracer
 	destination common.HostPort
+	kind        trace.SpanKind
 	commonAttrs []attribute.KeyValue
+	parent      *l7.TraceContext
+	end         time.Time
 }
 
-func (t *Trace) createSpan(name string, duration time.Duration, error bool, attrs ...attribute.KeyValue) {
+// WithParent returns a copy of the trace whose spans are created as children of the span propagated in the request
+func (t *Trace) WithParent(parent *l7.TraceContext) *Trace {
+	if t == nil || parent == nil {
+		return t
+	}
+	tt := *t
+	tt.parent = parent
+	return &tt
+}
+
+// WithEndTime returns a copy of the trace whose spans end at the given time (e.g., when the response was observed by the kernel)
+// rather than at the time they are created
+func (t *Trace) WithEndTime(end time.Time) *Trace {
+	if t == nil || end.IsZero() {
+		return t
+	}
+	tt := *t
+	tt.end = end
+	return &tt
+}
+
+func (t *Trace) createSpan(protocol l7.Protocol, name string, duration time.Duration, error bool, attrs ...attribute.KeyValue) {
+	t.createSpanOfKind(t.kind, protocol, name, duration, error, attrs...)
+}
+
+func (t *Trace) createSpanOfKind(kind trace.SpanKind, protocol l7.Protocol, name string, duration time.Duration, error bool, attrs ...attribute.KeyValue) {
 	if t.tracer.otel == nil {
 		return
 	}
-	end := time.Now()
+	if !t.tracer.sampler.sample(t.parent, protocol, duration, error) {
+		return
+	}
+	end := t.end
+	if end.IsZero() {
+		end = time.Now()
+	}
 	start := end.Add(-duration)
-	_, span := t.tracer.otel.Start(nil, name, trace.WithTimestamp(start), trace.WithSpanKind(trace.SpanKindClient))
+	_, span := t.tracer.otel.Start(t.parentContext(), name, trace.WithTimestamp(start), trace.WithSpanKind(kind))
 	span.SetAttributes(attrs...)
 	span.SetAttributes(t.commonAttrs...)
 	if error {
@@ -113,18 +167,50 @@ func (t *Trace) createSpan(name string, duration time.Duration, error bool, attr
 	span.End(trace.WithTimestamp(end))
 }
 
-func (t *Trace) HttpRequest(method, path string, status l7.Status, duration time.Duration) {
+func (t *Trace) parentContext() context.Context {
+	ctx := context.Background()
+	if t.parent == nil {
+		return ctx
+	}
+	cfg := trace.SpanContextConfig{TraceID: t.parent.TraceId, SpanID: t.parent.SpanId, Remote: true}
+	if t.parent.Sampled {
+		cfg.TraceFlags = trace.FlagsSampled
+	}
+	if t.parent.TraceState != "" {
+		if ts, err := trace.ParseTraceState(t.parent.TraceState); err == nil {
+			cfg.TraceState = ts
+		}
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(cfg))
+}
+
+func (t *Trace) HttpRequest(method, path, route string, cloud *l7.CloudRequest, graphql *l7.GraphqlOperation, status l7.Status, duration time.Duration) {
 	if t == nil || method == "" {
 		return
 	}
-	t.createSpan(method, duration, status >= 400,
+	attrs := []attribute.KeyValue{
 		semconv.HTTPURL(fmt.Sprintf("http://%s%s", t.destination.String(), path)),
 		semconv.HTTPMethod(method),
+		semconv.HTTPRoute(route),
 		semconv.HTTPStatusCode(int(status)),
-	)
+	}
+	attrs = append(attrs, cloudAttrs(cloud)...)
+	name := httpSpanName(method, route, cloud)
+	if graphql != nil {
+		if graphql.Type != "" {
+			attrs = append(attrs, semconv.GraphqlOperationTypeKey.String(graphql.Type))
+		}
+		if graphql.Name != "" {
+			attrs = append(attrs, semconv.GraphqlOperationName(graphql.Name))
+		}
+		if graphql.Type != "" && graphql.Name != "" {
+			name = graphql.Type + " " + graphql.Name
+		}
+	}
+	t.createSpan(l7.ProtocolHTTP, name, duration, status >= 400, attrs...)
 }
 
-func (t *Trace) Http2Request(method, path, scheme string, status l7.Status, duration time.Duration) {
+func (t *Trace) Http2Request(method, path, route, scheme string, cloud *l7.CloudRequest, status l7.Status, duration time.Duration) {
 	if t == nil {
 		return
 	}
@@ -137,44 +223,184 @@ func (t *Trace) Http2Request(method, path, scheme string, status l7.Status, dura
 	if scheme == "" {
 		scheme = "unknown"
 	}
-	t.createSpan(method, duration, status > 400,
+	attrs := []attribute.KeyValue{
 		semconv.HTTPURL(fmt.Sprintf("%s://%s%s", scheme, t.destination.String(), path)),
 		semconv.HTTPMethod(method),
+		semconv.HTTPRoute(route),
 		semconv.HTTPStatusCode(int(status)),
+	}
+	t.createSpan(l7.ProtocolHTTP2, httpSpanName(method, route, cloud), duration, status > 400, append(attrs, cloudAttrs(cloud)...)...)
+}
+
+func (t *Trace) FastcgiRequest(r l7.FastcgiRequest, route string, status l7.Status, duration time.Duration) {
+	if t == nil || r.Method == "" {
+		return
+	}
+	t.createSpan(l7.ProtocolFastcgi, httpSpanName(r.Method, route, nil), duration, status >= 400,
+		semconv.HTTPMethod(r.Method),
+		semconv.HTTPTarget(r.Uri),
+		semconv.HTTPRoute(route),
+		semconv.HTTPStatusCode(int(status)),
+		attribute.Key("fastcgi.script_name").String(r.Script),
 	)
 }
 
-func (t *Trace) PostgresQuery(query string, error bool, duration time.Duration) {
+// httpSpanName returns the name of the HTTP span, or service.operation (e.g., s3.GetObject) for the cloud API calls
+func httpSpanName(method, route string, cloud *l7.CloudRequest) string {
+	if cloud != nil {
+		return cloud.Service + "." + cloud.Operation
+	}
+	if route == "" {
+		return method
+	}
+	return method + " " + route
+}
+
+func cloudAttrs(r *l7.CloudRequest) []attribute.KeyValue {
+	if r == nil {
+		return nil
+	}
+	attrs := []attribute.KeyValue{
+		semconv.CloudProviderKey.String(r.Provider),
+		attribute.Key("cloud.service").String(r.Service),
+		attribute.Key("cloud.operation").String(r.Operation),
+	}
+	if r.Resource != "" {
+		attrs = append(attrs, attribute.Key("cloud.resource").String(r.Resource))
+	}
+	return attrs
+}
+
+func (t *Trace) GrpcRequest(service, method string, status l7.GrpcStatus, duration time.Duration) {
+	if t == nil || service == "" {
+		return
+	}
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemGRPC,
+		semconv.RPCService(service),
+		semconv.RPCMethod(method),
+	}
+	if status != l7.GrpcStatusUnknown {
+		attrs = append(attrs, semconv.RPCGRPCStatusCodeKey.Int(int(status)))
+	}
+	t.createSpan(l7.ProtocolGrpc, service+"/"+method, duration, status.Error(), attrs...)
+}
+
+// dbStatement returns the db.statement attribute according to the --traces-statement-obfuscation mode
+func dbStatement(statement string, obfuscate func(string) string) []attribute.KeyValue {
+	switch *flags.TracesStatementObfuscation {
+	case "drop-statement":
+		return nil
+	case "obfuscate":
+		statement = obfuscate(statement)
+	}
+	return []attribute.KeyValue{semconv.DBStatement(statement)}
+}
+
+// dbSensitive returns the attributes only if statements are recorded as is: keys, paths and error messages
+// may contain literal values (e.g., `Key (email)=(...) already exists`), which can't be reliably obfuscated
+func dbSensitive(attrs ...attribute.KeyValue) []attribute.KeyValue {
+	switch *flags.TracesStatementObfuscation {
+	case "obfuscate", "drop-statement":
+		return nil
+	}
+	return attrs
+}
+
+// double-quoted strings are identifiers in Postgres, ClickHouse and Cassandra
+func obfuscateSQL(query string) string {
+	return common.ObfuscateSQL(query, false)
+}
+
+func obfuscateMysql(query string) string {
+	return common.ObfuscateSQL(query, true)
+}
+
+func (t *Trace) PostgresQuery(query string, pgErr *l7.PostgresError, error bool, duration time.Duration) {
 	if t == nil || query == "" {
 		return
 	}
-	t.createSpan("query", duration, error,
-		semconv.DBSystemPostgreSQL,
-		semconv.DBStatement(query),
-	)
+	attrs := append([]attribute.KeyValue{semconv.DBSystemPostgreSQL}, dbStatement(query, obfuscateSQL)...)
+	if pgErr != nil {
+		attrs = append(attrs,
+			attribute.String("db.postgresql.sqlstate", pgErr.Code),
+			attribute.String("db.postgresql.severity", pgErr.Severity),
+		)
+		attrs = append(attrs, dbSensitive(attribute.String("db.postgresql.error_message", pgErr.Message))...)
+	}
+	t.createSpan(l7.ProtocolPostgres, "query", duration, error, attrs...)
 }
 
 func (t *Trace) MysqlQuery(query string, error bool, duration time.Duration) {
 	if t == nil || query == "" {
 		return
 	}
-	t.createSpan("query", duration, error,
-		semconv.DBSystemMySQL,
-		semconv.DBStatement(query),
-	)
+	attrs := append([]attribute.KeyValue{semconv.DBSystemMySQL}, dbStatement(query, obfuscateMysql)...)
+	t.createSpan(l7.ProtocolMysql, "query", duration, error, attrs...)
 }
 
-func (t *Trace) MongoQuery(query string, error bool, duration time.Duration) {
+func (t *Trace) MssqlQuery(query string, mssqlErr *l7.MssqlError, error bool, duration time.Duration) {
 	if t == nil || query == "" {
 		return
 	}
-	t.createSpan("query", duration, error,
-		semconv.DBSystemMongoDB,
-		semconv.DBStatement(query),
-	)
+	attrs := append([]attribute.KeyValue{semconv.DBSystemMSSQL}, dbStatement(query, obfuscateSQL)...)
+	if mssqlErr != nil {
+		attrs = append(attrs,
+			attribute.Key("db.mssql.error_number").Int(mssqlErr.Number),
+			attribute.Key("db.mssql.error_class").Int(mssqlErr.Class),
+		)
+		attrs = append(attrs, dbSensitive(attribute.Key("db.mssql.error_message").String(mssqlErr.Message))...)
+	}
+	t.createSpan(l7.ProtocolMssql, "query", duration, error, attrs...)
 }
 
-func (t *Trace) MemcachedQuery(cmd string, items []string, error bool, duration time.Duration) {
+func (t *Trace) MongoQuery(query string, cmd *l7.MongoCommand, reply *l7.MongoReply, error bool, duration time.Duration) {
+	if t == nil || query == "" {
+		return
+	}
+	attrs := append([]attribute.KeyValue{semconv.DBSystemMongoDB}, dbStatement(query, common.ObfuscateMongo)...)
+	name := "query"
+	if cmd != nil {
+		name = cmd.Name
+		attrs = append(attrs, semconv.DBOperation(cmd.Name))
+		if cmd.Db != "" {
+			attrs = append(attrs, semconv.DBName(cmd.Db))
+		}
+		if cmd.Collection != "" {
+			attrs = append(attrs, semconv.DBMongoDBCollection(cmd.Collection))
+			name += " " + cmd.Collection
+		}
+	}
+	if reply != nil && !reply.Ok {
+		attrs = append(attrs,
+			attribute.Key("db.mongodb.error_code").Int(reply.Code),
+			attribute.Key("db.mongodb.error_name").String(reply.CodeName),
+		)
+		attrs = append(attrs, dbSensitive(attribute.Key("db.mongodb.error_message").String(reply.ErrMsg))...)
+	}
+	t.createSpan(l7.ProtocolMongo, name, duration, error, attrs...)
+}
+
+func (t *Trace) ElasticsearchRequest(r *l7.ElasticsearchRequest, method, path string, status l7.Status, duration time.Duration) {
+	if t == nil || r == nil {
+		return
+	}
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemElasticsearch,
+		semconv.DBOperation(r.Operation),
+		semconv.HTTPMethod(method),
+		semconv.HTTPURL(fmt.Sprintf("http://%s%s", t.destination.String(), path)),
+		semconv.HTTPStatusCode(int(status)),
+	}
+	name := r.Operation
+	if r.Index != "" {
+		attrs = append(attrs, attribute.Key("db.elasticsearch.index").String(r.Index))
+		name += " " + r.Index
+	}
+	t.createSpan(l7.ProtocolElasticsearch, name, duration, status >= 400, attrs...)
+}
+
+func (t *Trace) MemcachedQuery(cmd string, items []string, status, errMsg string, error bool, duration time.Duration) {
 	if t == nil || cmd == "" {
 		return
 	}
@@ -183,38 +409,180 @@ func (t *Trace) MemcachedQuery(cmd string, items []string, error bool, duration
 		semconv.DBOperation(cmd),
 	}
 	if len(items) == 1 {
-		attrs = append(attrs, MemcacheDBItemKeyName.String(items[0]))
+		attrs = append(attrs, dbSensitive(MemcacheDBItemKeyName.String(items[0]))...)
 	} else if len(items) > 1 {
-		attrs = append(attrs, MemcacheDBItemKeyName.StringSlice(items))
+		attrs = append(attrs, dbSensitive(MemcacheDBItemKeyName.StringSlice(items))...)
+		attrs = append(attrs, attribute.Key("db.memcached.items_count").Int(len(items)))
+	}
+	if error {
+		attrs = append(attrs, attribute.Key("db.memcached.status").String(status))
+		if errMsg != "" {
+			attrs = append(attrs, dbSensitive(attribute.Key("db.memcached.error_message").String(errMsg))...)
+		}
 	}
-	t.createSpan(cmd, duration, error, attrs...)
+	t.createSpan(l7.ProtocolMemcached, cmd, duration, error, attrs...)
 }
 
-func (t *Trace) RedisQuery(cmd, args string, error bool, duration time.Duration) {
-	if t == nil || cmd == "" {
+func (t *Trace) RedisQuery(commands []l7.RedisCommand, redisErr string, error bool, duration time.Duration) {
+	if t == nil || len(commands) == 0 {
 		return
 	}
-	statement := cmd
-	if args != "" {
-		statement += " " + args
+	statements := make([]string, 0, len(commands))
+	obfuscated := make([]string, 0, len(commands))
+	for _, c := range commands {
+		statement := c.Cmd
+		if c.Args != "" {
+			statement += " " + c.Args
+		}
+		statements = append(statements, statement)
+		obfuscated = append(obfuscated, common.ObfuscateRedis(c.Cmd, c.Args))
 	}
-	t.createSpan(cmd, duration, error,
-		semconv.DBSystemRedis,
-		semconv.DBOperation(cmd),
-		semconv.DBStatement(statement),
+	cmd := commands[0].Cmd
+	attrs := append(
+		[]attribute.KeyValue{semconv.DBSystemRedis, semconv.DBOperation(cmd)},
+		dbStatement(strings.Join(statements, "\n"), func(string) string { return strings.Join(obfuscated, "\n") })...,
 	)
+	if len(commands) > 1 {
+		attrs = append(attrs, attribute.Key("db.redis.pipeline_length").Int(len(commands)))
+	}
+	if redisErr != "" {
+		attrs = append(attrs, attribute.Key("db.redis.error").String(redisErr))
+	}
+	t.createSpan(l7.ProtocolRedis, cmd, duration, error, attrs...)
 }
 
-func (t *Trace) ClickhouseQuery(query string, error bool, duration time.Duration) {
+func (t *Trace) CassandraQuery(query, keyspace string, error bool, duration time.Duration) {
+	if t == nil || query == "" {
+		return
+	}
+	attrs := append([]attribute.KeyValue{semconv.DBSystemCassandra}, dbStatement(query, obfuscateSQL)...)
+	if keyspace != "" {
+		attrs = append(attrs, semconv.DBName(keyspace))
+	}
+	t.createSpan(l7.ProtocolCassandra, "query", duration, error, attrs...)
+}
+
+func (t *Trace) RabbitmqMessage(method l7.Method, exchange, routingKey string, error bool) {
 	if t == nil {
 		return
 	}
-	t.createSpan("query", duration, error,
-		semconv.DBSystemClickhouse,
-		semconv.DBStatement(query),
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystem("rabbitmq"),
+		semconv.MessagingDestinationName(exchange),
+	}
+	if routingKey != "" {
+		attrs = append(attrs, semconv.MessagingRabbitmqDestinationRoutingKey(routingKey))
+	}
+	destination := exchange
+	if destination == "" {
+		destination = "(default)"
+	}
+	t.messagingSpan(l7.ProtocolRabbitmq, method, destination, 0, error, attrs...)
+}
+
+func (t *Trace) NatsMessage(method l7.Method, subject string, error bool) {
+	if t == nil || subject == "" {
+		return
+	}
+	t.messagingSpan(l7.ProtocolNats, method, subject, 0, error,
+		semconv.MessagingSystem("nats"),
+		semconv.MessagingDestinationName(subject),
 	)
 }
 
+func (t *Trace) MqttRequest(r l7.MqttRequest) {
+	if t == nil || r.Packet == "" {
+		return
+	}
+	attrs := []attribute.KeyValue{semconv.MessagingSystem("mqtt")}
+	if r.Failed {
+		attrs = append(attrs, attribute.Key("messaging.mqtt.reason").String(r.Status))
+	}
+	if r.Method != l7.MethodUnknown {
+		topic := r.Topics[0]
+		attrs = append(attrs, semconv.MessagingDestinationName(topic), attribute.Key("messaging.mqtt.qos").Int(r.Qos))
+		t.messagingSpan(l7.ProtocolMqtt, r.Method, topic, r.Duration, r.Failed, attrs...)
+		return
+	}
+	name := r.Packet
+	switch len(r.Topics) {
+	case 0:
+	case 1:
+		name += " " + r.Topics[0]
+		attrs = append(attrs, semconv.MessagingDestinationName(r.Topics[0]))
+	default:
+		attrs = append(attrs, attribute.Key("messaging.mqtt.topics").StringSlice(r.Topics))
+	}
+	t.createSpanOfKind(trace.SpanKindClient, l7.ProtocolMqtt, name, r.Duration, r.Failed, attrs...)
+}
+
+func (t *Trace) PulsarRequest(r l7.PulsarRequest) {
+	if t == nil || r.Topic == "" {
+		return
+	}
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystem("pulsar"),
+		semconv.MessagingDestinationName(r.Topic),
+	}
+	if r.Failed {
+		attrs = append(attrs,
+			attribute.Key("messaging.pulsar.error").String(r.Status),
+			attribute.Key("messaging.pulsar.error_message").String(r.Error),
+		)
+	}
+	switch r.Command {
+	case "SEND":
+		if r.Messages > 1 {
+			attrs = append(attrs, semconv.MessagingBatchMessageCount(r.Messages))
+		}
+		t.messagingSpan(l7.ProtocolPulsar, l7.MethodProduce, r.Topic, r.Duration, r.Failed, attrs...)
+	case "MESSAGE":
+		t.messagingSpan(l7.ProtocolPulsar, l7.MethodConsume, r.Topic, 0, r.Failed, attrs...)
+	case "PRODUCER", "SUBSCRIBE":
+		t.createSpanOfKind(trace.SpanKindClient, l7.ProtocolPulsar, r.Command+" "+r.Topic, r.Duration, r.Failed, attrs...)
+	}
+}
+
+func (t *Trace) messagingSpan(protocol l7.Protocol, method l7.Method, destination string, duration time.Duration, error bool, attrs ...attribute.KeyValue) {
+	switch method {
+	case l7.MethodProduce:
+		attrs = append(attrs, semconv.MessagingOperationPublish)
+		t.createSpanOfKind(trace.SpanKindProducer, protocol, destination+" publish", duration, error, attrs...)
+	case l7.MethodConsume:
+		attrs = append(attrs, semconv.MessagingOperationReceive)
+		t.createSpanOfKind(trace.SpanKindConsumer, protocol, destination+" receive", duration, error, attrs...)
+	}
+}
+
+func (t *Trace) Dubbo2Request(service, method string, status l7.Status, dubboStatus l7.Dubbo2Status, duration time.Duration) {
+	if t == nil || service == "" {
+		return
+	}
+	name := service
+	if method != "" {
+		name += "/" + method
+	}
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemApacheDubbo,
+		semconv.RPCService(service),
+	}
+	if method != "" {
+		attrs = append(attrs, semconv.RPCMethod(method))
+	}
+	if dubboStatus != 0 {
+		attrs = append(attrs, attribute.Key("rpc.dubbo.status_code").Int(int(dubboStatus)))
+	}
+	t.createSpan(l7.ProtocolDubbo2, name, duration, status.Error(), attrs...)
+}
+
+func (t *Trace) ClickhouseQuery(query string, error bool, duration time.Duration) {
+	if t == nil {
+		return
+	}
+	attrs := append([]attribute.KeyValue{semconv.DBSystemClickhouse}, dbStatement(query, obfuscateSQL)...)
+	t.createSpan(l7.ProtocolClickhouse, "query", duration, error, attrs...)
+}
+
 func (t *Trace) ZookeeperRequest(op string, args string, status l7.Status, duration time.Duration) {
 	if t == nil {
 		return
@@ -226,10 +594,40 @@ func (t *Trace) ZookeeperRequest(op string, args string, status l7.Status, durat
 	if args != "" {
 		statement += " " + args
 	}
-	t.createSpan(op, duration, status.Zookeeper() != "ok",
+	attrs := []attribute.KeyValue{
 		semconv.DBSystemKey.String("zookeeper"),
 		semconv.DBOperation(op),
-		semconv.DBStatementKey.String(statement),
 		attribute.Key("zookeeper.status_code").Int(int(status)),
-	)
+	}
+	attrs = append(attrs, dbStatement(statement, func(string) string { return op })...) // znode paths may contain data
+	t.createSpan(l7.ProtocolZookeeper, op, duration, status.Zookeeper() != "ok", attrs...)
+}
+
+func (t *Trace) KafkaRequest(r *l7.KafkaRequest, error bool, duration time.Duration) {
+	if t == nil || r == nil {
+		return
+	}
+	op := r.Operation()
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystem("kafka"),
+		attribute.Key("messaging.kafka.api_key").String(op),
+		attribute.Key("messaging.kafka.api_version").Int(int(r.ApiVersion)),
+	}
+	switch r.ApiKey {
+	case l7.KafkaApiKeyProduce:
+		attrs = append(attrs, semconv.MessagingOperationPublish)
+	case l7.KafkaApiKeyFetch:
+		attrs = append(attrs, semconv.MessagingOperationReceive)
+	}
+	if r.ClientId != "" {
+		attrs = append(attrs, semconv.MessagingKafkaClientID(r.ClientId))
+	}
+	name := op
+	if len(r.Topics) == 1 {
+		attrs = append(attrs, semconv.MessagingDestinationName(r.Topics[0]))
+		name = r.Topics[0] + " " + op
+	} else if len(r.Topics) > 1 {
+		attrs = append(attrs, attribute.Key("messaging.kafka.topics").StringSlice(r.Topics))
+	}
+	t.createSpan(l7.ProtocolKafka, name, duration, error, attrs...)
 }